@@ -4,290 +4,110 @@ import rego.v1
 
 # Test cases for CNSCC-STO-03.01 compliance
 
+# Resource changes for a bucket with every data integrity control configured
+compliant_resources(bucket_name) := [
+	{
+		"type": "aws_s3_bucket",
+		"change": {
+			"actions": ["create"],
+			"after": {"bucket": bucket_name},
+		},
+	},
+	{
+		"type": "aws_s3_bucket_versioning",
+		"change": {"after": {
+			"bucket": bucket_name,
+			"versioning_configuration": [{"status": "Enabled"}],
+		}},
+	},
+	{
+		"type": "aws_s3_bucket_server_side_encryption_configuration",
+		"change": {"after": {
+			"bucket": bucket_name,
+			"rule": [{"apply_server_side_encryption_by_default": [{"sse_algorithm": "AES256"}]}],
+		}},
+	},
+	{
+		"type": "aws_s3_bucket_policy",
+		"change": {"after": {
+			"bucket": bucket_name,
+			"policy": json.marshal({"Statement": [{
+				"Effect": "Deny",
+				"Condition": {"Bool": {"aws:SecureTransport": "false"}},
+			}]}),
+		}},
+	},
+	{
+		"type": "aws_s3_bucket_lifecycle_configuration",
+		"change": {"after": {
+			"bucket": bucket_name,
+			"rule": [{"status": "Enabled"}],
+		}},
+	},
+	{
+		"type": "aws_s3_bucket_notification",
+		"change": {"after": {"bucket": bucket_name}},
+	},
+]
+
+# Resource changes for a compliant bucket with one control removed
+resources_without(bucket_name, resource_type) := [resource |
+	some resource in compliant_resources(bucket_name)
+	resource.type != resource_type
+]
+
 # Test 1: Compliant configuration should pass
-test_compliant_configuration {
-    input := {
-        "resource_changes": [
-            {
-                "type": "aws_s3_bucket",
-                "change": {
-                    "actions": ["create"],
-                    "after": {
-                        "bucket": "compliant-bucket"
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_versioning",
-                "change": {
-                    "after": {
-                        "bucket": "compliant-bucket",
-                        "versioning_configuration": [{"status": "Enabled"}]
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_server_side_encryption_configuration",
-                "change": {
-                    "after": {
-                        "bucket": "compliant-bucket",
-                        "rule": [{
-                            "apply_server_side_encryption_by_default": [{"sse_algorithm": "AES256"}]
-                        }]
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_policy",
-                "change": {
-                    "after": {
-                        "bucket": "compliant-bucket",
-                        "policy": json.marshal({
-                            "Statement": [{
-                                "Effect": "Deny",
-                                "Condition": {
-                                    "Bool": {
-                                        "aws:SecureTransport": "false"
-                                    }
-                                }
-                            }]
-                        })
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_lifecycle_configuration",
-                "change": {
-                    "after": {
-                        "bucket": "compliant-bucket",
-                        "rule": [{"status": "Enabled"}]
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_notification",
-                "change": {
-                    "after": {
-                        "bucket": "compliant-bucket"
-                    }
-                }
-            }
-        ]
-    }
-    
-    deny(input) == []
+test_compliant_configuration if {
+	count(deny) == 0 with input as {"resource_changes": compliant_resources("compliant-bucket")}
 }
 
 # Test 2: Missing versioning should fail
-test_missing_versioning {
-    input := {
-        "resource_changes": [
-            {
-                "type": "aws_s3_bucket",
-                "change": {
-                    "actions": ["create"],
-                    "after": {
-                        "bucket": "no-versioning-bucket"
-                    }
-                }
-            }
-        ]
-    }
-    
-    count(deny(input)) == 1
-    deny(input)[0] contains "versioning must be enabled"
+test_missing_versioning if {
+	denials := deny with input as {"resource_changes": resources_without("no-versioning-bucket", "aws_s3_bucket_versioning")}
+	count(denials) == 1
+	some msg in denials
+	contains(msg, "Versioning must be enabled")
 }
 
 # Test 3: Missing encryption should fail
-test_missing_encryption {
-    input := {
-        "resource_changes": [
-            {
-                "type": "aws_s3_bucket",
-                "change": {
-                    "actions": ["create"],
-                    "after": {
-                        "bucket": "no-encryption-bucket"
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_versioning",
-                "change": {
-                    "after": {
-                        "bucket": "no-encryption-bucket",
-                        "versioning_configuration": [{"status": "Enabled"}]
-                    }
-                }
-            }
-        ]
-    }
-    
-    count(deny(input)) == 1
-    deny(input)[0] contains "server-side encryption must be enabled"
+test_missing_encryption if {
+	denials := deny with input as {"resource_changes": resources_without("no-encryption-bucket", "aws_s3_bucket_server_side_encryption_configuration")}
+	count(denials) == 1
+	some msg in denials
+	contains(msg, "Server-side encryption must be enabled")
 }
 
 # Test 4: Missing secure transport enforcement should fail
-test_missing_secure_transport {
-    input := {
-        "resource_changes": [
-            {
-                "type": "aws_s3_bucket",
-                "change": {
-                    "actions": ["create"],
-                    "after": {
-                        "bucket": "no-secure-transport-bucket"
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_versioning",
-                "change": {
-                    "after": {
-                        "bucket": "no-secure-transport-bucket",
-                        "versioning_configuration": [{"status": "Enabled"}]
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_server_side_encryption_configuration",
-                "change": {
-                    "after": {
-                        "bucket": "no-secure-transport-bucket",
-                        "rule": [{
-                            "apply_server_side_encryption_by_default": [{"sse_algorithm": "AES256"}]
-                        }]
-                    }
-                }
-            }
-        ]
-    }
-    
-    count(deny(input)) == 1
-    deny(input)[0] contains "secure transport must be enforced"
+test_missing_secure_transport if {
+	denials := deny with input as {"resource_changes": resources_without("no-secure-transport-bucket", "aws_s3_bucket_policy")}
+	count(denials) == 1
+	some msg in denials
+	contains(msg, "Secure transport must be enforced")
 }
 
 # Test 5: Missing lifecycle configuration should fail
-test_missing_lifecycle {
-    input := {
-        "resource_changes": [
-            {
-                "type": "aws_s3_bucket",
-                "change": {
-                    "actions": ["create"],
-                    "after": {
-                        "bucket": "no-lifecycle-bucket"
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_versioning",
-                "change": {
-                    "after": {
-                        "bucket": "no-lifecycle-bucket",
-                        "versioning_configuration": [{"status": "Enabled"}]
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_server_side_encryption_configuration",
-                "change": {
-                    "after": {
-                        "bucket": "no-lifecycle-bucket",
-                        "rule": [{
-                            "apply_server_side_encryption_by_default": [{"sse_algorithm": "AES256"}]
-                        }]
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_policy",
-                "change": {
-                    "after": {
-                        "bucket": "no-lifecycle-bucket",
-                        "policy": json.marshal({
-                            "Statement": [{
-                                "Effect": "Deny",
-                                "Condition": {
-                                    "Bool": {
-                                        "aws:SecureTransport": "false"
-                                    }
-                                }
-                            }]
-                        })
-                    }
-                }
-            }
-        ]
-    }
-    
-    count(deny(input)) == 1
-    deny(input)[0] contains "lifecycle configuration must be present"
+test_missing_lifecycle if {
+	denials := deny with input as {"resource_changes": resources_without("no-lifecycle-bucket", "aws_s3_bucket_lifecycle_configuration")}
+	count(denials) == 1
+	some msg in denials
+	contains(msg, "Lifecycle configuration must be present")
 }
 
 # Test 6: Missing monitoring should fail
-test_missing_monitoring {
-    input := {
-        "resource_changes": [
-            {
-                "type": "aws_s3_bucket",
-                "change": {
-                    "actions": ["create"],
-                    "after": {
-                        "bucket": "no-monitoring-bucket"
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_versioning",
-                "change": {
-                    "after": {
-                        "bucket": "no-monitoring-bucket",
-                        "versioning_configuration": [{"status": "Enabled"}]
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_server_side_encryption_configuration",
-                "change": {
-                    "after": {
-                        "bucket": "no-monitoring-bucket",
-                        "rule": [{
-                            "apply_server_side_encryption_by_default": [{"sse_algorithm": "AES256"}]
-                        }]
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_policy",
-                "change": {
-                    "after": {
-                        "bucket": "no-monitoring-bucket",
-                        "policy": json.marshal({
-                            "Statement": [{
-                                "Effect": "Deny",
-                                "Condition": {
-                                    "Bool": {
-                                        "aws:SecureTransport": "false"
-                                    }
-                                }
-                            }]
-                        })
-                    }
-                }
-            },
-            {
-                "type": "aws_s3_bucket_lifecycle_configuration",
-                "change": {
-                    "after": {
-                        "bucket": "no-monitoring-bucket",
-                        "rule": [{"status": "Enabled"}]
-                    }
-                }
-            }
-        ]
-    }
-    
-    count(deny(input)) == 1
-    deny(input)[0] contains "bucket monitoring must be enabled"
-}
\ No newline at end of file
+test_missing_monitoring if {
+	denials := deny with input as {"resource_changes": resources_without("no-monitoring-bucket", "aws_s3_bucket_notification")}
+	count(denials) == 1
+	some msg in denials
+	contains(msg, "Bucket monitoring must be enabled")
+}
+
+# Test 7: Buckets that are not being created are not evaluated
+test_existing_bucket_ignored if {
+	count(deny) == 0 with input as {"resource_changes": [{
+		"type": "aws_s3_bucket",
+		"change": {
+			"actions": ["update"],
+			"after": {"bucket": "existing-bucket"},
+		},
+	}]}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var packagePattern = regexp.MustCompile(`^package\s+([A-Za-z_][A-Za-z0-9_.]*)`)
+
+// loadCheckNamespaces maps each Rego package in a policy directory pulled by Conftest to
+// the checks that declare it. Policies are stored at <check ID>/... in the directory.
+func loadCheckNamespaces(dir string) (map[string][]string, error) {
+	namespaces := make(map[string][]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".rego" || strings.HasSuffix(path, "_test.rego") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		checkID, _, ok := strings.Cut(filepath.ToSlash(rel), "/")
+		if !ok {
+			return nil
+		}
+		pkg, err := regoPackage(path)
+		if err != nil {
+			return err
+		}
+		if pkg != "" && !slices.Contains(namespaces[pkg], checkID) {
+			namespaces[pkg] = append(namespaces[pkg], checkID)
+		}
+		return nil
+	})
+	return namespaces, err
+}
+
+// regoPackage returns the package declared in a Rego file.
+func regoPackage(path string) (string, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if match := packagePattern.FindStringSubmatch(strings.TrimSpace(scanner.Text())); match != nil {
+			return match[1], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// summarize returns a summary for every check evaluated against each file, in the order
+// the files and checks first appear. A check is evaluated against a file if one of its
+// namespaces was tested against the file or it has a finding for the file.
+func summarize(results []ConftestFileResult, namespaces map[string][]string) []CheckSummary {
+	type key struct{ fileName, checkID string }
+	var summaries []*CheckSummary
+	byKey := make(map[key]*CheckSummary)
+	summary := func(fileName, checkID string) *CheckSummary {
+		k := key{fileName, checkID}
+		s, ok := byKey[k]
+		if !ok {
+			s = &CheckSummary{CheckID: checkID, FileName: fileName}
+			byKey[k] = s
+			summaries = append(summaries, s)
+		}
+		return s
+	}
+
+	for _, result := range results {
+		for _, checkID := range namespaces[result.Namespace] {
+			s := summary(result.FileName, checkID)
+			s.Successes += result.Successes
+			if !slices.Contains(s.Namespaces, result.Namespace) {
+				s.Namespaces = append(s.Namespaces, result.Namespace)
+			}
+		}
+		for _, finding := range result.Failures {
+			if checkID, ok := finding.Metadata["short_name"].(string); ok {
+				summary(result.FileName, checkID).Failures++
+			}
+		}
+		for _, finding := range result.Warnings {
+			if checkID, ok := finding.Metadata["short_name"].(string); ok {
+				summary(result.FileName, checkID).Warnings++
+			}
+		}
+	}
+
+	flattened := make([]CheckSummary, 0, len(summaries))
+	for _, s := range summaries {
+		flattened = append(flattened, *s)
+	}
+	return flattened
+}
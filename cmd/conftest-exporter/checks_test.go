@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadCheckNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"check-a/policy.rego":      "package main\n",
+		"check-a/policy_test.rego": "package main_test\n",
+		"check-a/data.json":        "{}",
+		"check-b/nested/s3.rego":   "# METADATA\npackage terraform.aws.s3\n",
+		"check-c/policy.rego":      "package main\n",
+		"top.rego":                 "package ignored\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := loadCheckNamespaces(dir)
+	if err != nil {
+		t.Fatalf("loadCheckNamespaces() error = %v", err)
+	}
+	want := map[string][]string{
+		"main":             {"check-a", "check-c"},
+		"terraform.aws.s3": {"check-b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadCheckNamespaces() = %v, want %v", got, want)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	finding := func(checkID string) ConftestFinding {
+		return ConftestFinding{Message: "violation", Metadata: map[string]interface{}{"short_name": checkID}}
+	}
+	namespaces := map[string][]string{"main": {"check-a", "check-b"}}
+
+	tests := []struct {
+		name    string
+		results []ConftestFileResult
+		want    []CheckSummary
+	}{
+		{
+			name:    "clean run",
+			results: []ConftestFileResult{{FileName: "input.json", Namespace: "main", Successes: 4}},
+			want: []CheckSummary{
+				{CheckID: "check-a", FileName: "input.json", Namespaces: []string{"main"}, Successes: 4},
+				{CheckID: "check-b", FileName: "input.json", Namespaces: []string{"main"}, Successes: 4},
+			},
+		},
+		{
+			name: "findings are counted for their check",
+			results: []ConftestFileResult{{
+				FileName:  "input.json",
+				Namespace: "main",
+				Successes: 2,
+				Failures:  []ConftestFinding{finding("check-a"), finding("check-a")},
+				Warnings:  []ConftestFinding{finding("check-b")},
+			}},
+			want: []CheckSummary{
+				{CheckID: "check-a", FileName: "input.json", Namespaces: []string{"main"}, Successes: 2, Failures: 2},
+				{CheckID: "check-b", FileName: "input.json", Namespaces: []string{"main"}, Successes: 2, Warnings: 1},
+			},
+		},
+		{
+			name: "checks outside the policy directory are summarized from their findings",
+			results: []ConftestFileResult{{
+				FileName:  "plan.json",
+				Namespace: "terraform",
+				Failures:  []ConftestFinding{finding("check-c")},
+			}},
+			want: []CheckSummary{
+				{CheckID: "check-c", FileName: "plan.json", Failures: 1},
+			},
+		},
+		{
+			name: "files are summarized separately",
+			results: []ConftestFileResult{
+				{FileName: "a.json", Namespace: "main", Successes: 1},
+				{FileName: "b.json", Namespace: "other", Successes: 1},
+			},
+			want: []CheckSummary{
+				{CheckID: "check-a", FileName: "a.json", Namespaces: []string{"main"}, Successes: 1},
+				{CheckID: "check-b", FileName: "a.json", Namespaces: []string{"main"}, Successes: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarize(tt.results, namespaces)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("summarize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSummaryToOCSF(t *testing.T) {
+	tests := []struct {
+		name       string
+		summary    CheckSummary
+		wantStatus string
+	}{
+		{name: "pass", summary: CheckSummary{CheckID: "check-a", FileName: "input.json", Successes: 3}, wantStatus: "success"},
+		{name: "warnings pass", summary: CheckSummary{CheckID: "check-a", FileName: "input.json", Warnings: 1}, wantStatus: "success"},
+		{name: "failures fail", summary: CheckSummary{CheckID: "check-a", FileName: "input.json", Failures: 1}, wantStatus: "failure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evidence, err := tt.summary.ToOCSF()
+			if err != nil {
+				t.Fatalf("ToOCSF() error = %v", err)
+			}
+			if evidence.ActivityId != completedScanActivity {
+				t.Errorf("activity_id = %d, want %d", evidence.ActivityId, completedScanActivity)
+			}
+			if evidence.Status == nil || *evidence.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %s", evidence.Status, tt.wantStatus)
+			}
+			if evidence.Policy.Uid == nil || *evidence.Policy.Uid != "check-a" {
+				t.Errorf("policy uid = %v, want check-a", evidence.Policy.Uid)
+			}
+		})
+	}
+}
@@ -36,13 +36,14 @@ var (
 )
 
 func main() {
-	var endpoint, path string
+	var endpoint, path, policyPath string
 	var skipTLS, skipTLSVerify bool
 
 	flag.StringVar(&endpoint, "endpoint", "", "The OTEL Collector Endpoint")
 	flag.BoolVar(&skipTLS, "skip-tls", false, "Do not connect with TLS")
 	flag.BoolVar(&skipTLSVerify, "skip-tls-verify", false, "Do not verify certificates")
 	flag.StringVar(&path, "path", "", "Path to results")
+	flag.StringVar(&policyPath, "policy", "", "Path to the policy directory Conftest evaluated, used to record checks without findings")
 	flag.Parse()
 
 	conn, err := newClient(endpoint, true, true)
@@ -73,17 +74,43 @@ func main() {
 	if err := json.Unmarshal(file, &results); err != nil {
 		log.Fatal(err.Error())
 	}
+
+	namespaces := make(map[string][]string)
+	if policyPath != "" {
+		namespaces, err = loadCheckNamespaces(policyPath)
+		if err != nil {
+			log.Fatalf("failed to read policies from %s: %v", policyPath, err)
+		}
+	}
+
+	// Log every finding under one span so the evidence from this run shares a trace ID
+	// that consumers can use to find the latest evaluation run.
+	ctx, span := tracer.Start(context.Background(), "conftest-evaluation")
+	defer span.End()
+
 	for _, result := range results {
 		for _, finding := range result.Failures {
 			evidence, err := finding.ToOCSF(result.FileName)
 			if err != nil {
 				log.Fatal(err.Error())
 			}
-			if err := watcher.Log(context.Background(), evidence); err != nil {
+			if err := watcher.Log(ctx, evidence); err != nil {
 				log.Fatalf("failed to log evidence %v", err)
 			}
 		}
 	}
+
+	// Summarize the run for every check, so a check without failures is recorded as passing
+	// instead of being left with its findings from an earlier run.
+	for _, summary := range summarize(results, namespaces) {
+		evidence, err := summary.ToOCSF()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		if err := watcher.Log(ctx, evidence); err != nil {
+			log.Fatalf("failed to log evidence %v", err)
+		}
+	}
 }
 
 // otelSDKSetup completes setup of the Otel SDK with providers.
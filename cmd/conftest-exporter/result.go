@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	ocsf "github.com/Santiago-Labs/go-ocsf/ocsf/v1_5_0"
@@ -18,15 +19,84 @@ type ConftestFinding struct {
 	Metadata  map[string]interface{} `json:"metadata"`
 }
 
-// ConftestFileResult mirrors the structure of one file's results in the Conftest JSON output.
+// ConftestFileResult mirrors the structure of one file's results for one namespace in the
+// Conftest JSON output.
 type ConftestFileResult struct {
-	FileName string            `json:"fileName"`
-	Warnings []ConftestFinding `json:"warnings"`
-	Failures []ConftestFinding `json:"failures"`
+	FileName  string            `json:"fileName"`
+	Namespace string            `json:"namespace"`
+	Successes int               `json:"successes"`
+	Warnings  []ConftestFinding `json:"warnings"`
+	Failures  []ConftestFinding `json:"failures"`
+}
+
+// CheckSummary is the outcome of one check for one file in a Conftest run. It is logged
+// for every check evaluated against the file, so runs without findings are recorded too.
+type CheckSummary struct {
+	CheckID    string   `json:"check_id"`
+	FileName   string   `json:"file_name"`
+	Namespaces []string `json:"namespaces"`
+	Successes  int      `json:"successes"`
+	Failures   int      `json:"failures"`
+	Warnings   int      `json:"warnings"`
 }
 
 func (f ConftestFinding) ToOCSF(filename string) (proofwatch.Evidence, error) {
-	classUID := 6007
+	checkId, ok := f.Metadata["short_name"]
+	if !ok {
+		return proofwatch.Evidence{}, errors.New("expected short_name in metadata")
+	}
+
+	checkIdStr, ok := checkId.(string)
+	if !ok {
+		return proofwatch.Evidence{}, errors.New("expected short_name value to be a string")
+	}
+
+	policyData, err := json.Marshal(f)
+	if err != nil {
+		return proofwatch.Evidence{}, err
+	}
+
+	status, statusID := mapStatus(f)
+	activity := newScanActivity(filename, f.Message, status, statusID)
+	return newEvidence(activity, checkIdStr, string(policyData)), nil
+}
+
+// ToOCSF converts the summary to a completed scan. The scan fails if the check had any
+// failures for the file.
+func (s CheckSummary) ToOCSF() (proofwatch.Evidence, error) {
+	policyData, err := json.Marshal(s)
+	if err != nil {
+		return proofwatch.Evidence{}, err
+	}
+
+	status, statusID := "success", int32(1)
+	message := fmt.Sprintf("No violations found (%d successes)", s.Successes)
+	if s.Failures > 0 {
+		status, statusID = "failure", 2
+		message = fmt.Sprintf("%d failures and %d warnings found", s.Failures, s.Warnings)
+	} else if s.Warnings > 0 {
+		message = fmt.Sprintf("No violations found (%d successes, %d warnings)", s.Successes, s.Warnings)
+	}
+
+	activity := newScanActivity(s.FileName, message, status, statusID)
+	activityName := "Completed"
+	detections := int32(s.Failures + s.Warnings)
+	activity.ActivityId = completedScanActivity
+	activity.ActivityName = &activityName
+	activity.TypeUid = int64(scanActivityClass*100 + completedScanActivity)
+	activity.NumDetections = &detections
+	return newEvidence(activity, s.CheckID, string(policyData)), nil
+}
+
+// OCSF ScanActivity class and the activity of a completed scan.
+const (
+	scanActivityClass     = 6007
+	completedScanActivity = 2
+)
+
+// newScanActivity creates a scan of one file with the given outcome.
+func newScanActivity(filename, message, status string, statusID int32) ocsf.ScanActivity {
+	classUID := scanActivityClass
 	categoryUID := 6
 	categoryName := "Application Activity"
 	className := "Scan Activity"
@@ -39,15 +109,16 @@ func (f ConftestFinding) ToOCSF(filename string) (proofwatch.Evidence, error) {
 
 	vendorName := "conftest"
 	productName := "conftest"
-	action := "observed"
-	actionId := int32(3)
-	status, statusID := mapStatus(f)
 	numFiles := int32(1)
 	unknown := "unknown"
 	unknownID := int32(0)
 
 	uid := "conftest-exporter"
-	activity := ocsf.ScanActivity{
+	// The evaluated file identifies the resource the finding is about.
+	fileObservableName := "file.name"
+	fileObservableType := "File Name"
+	fileNameTypeID := int32(7)
+	return ocsf.ScanActivity{
 		ActivityId:   int32(activityID),
 		ActivityName: &activityName,
 		CategoryName: &categoryName,
@@ -59,7 +130,7 @@ func (f ConftestFinding) ToOCSF(filename string) (proofwatch.Evidence, error) {
 		Severity:     &unknown,
 		SeverityId:   unknownID,
 		NumFiles:     &numFiles,
-		Message:      &f.Message, // Include the violation message from the finding
+		Message:      &message, // The violation message of a finding or the outcome of a scan
 		Metadata: ocsf.Metadata{
 			Uid: &uid,
 			Product: ocsf.Product{
@@ -68,40 +139,35 @@ func (f ConftestFinding) ToOCSF(filename string) (proofwatch.Evidence, error) {
 			},
 			LogProvider: &productName,
 		},
+		Observables: []*ocsf.Observable{
+			{
+				Name:   &fileObservableName,
+				Type:   &fileObservableType,
+				TypeId: fileNameTypeID,
+				Value:  &filename,
+			},
+		},
 		Time:     time.Now().UnixMilli(),
 		TypeName: &typeName,
 		TypeUid:  int64(completedScan),
 	}
+}
 
-	policyData, err := json.Marshal(f)
-	if err != nil {
-		return proofwatch.Evidence{}, err
-	}
-	policyDataStr := string(policyData)
-
-	checkId, ok := f.Metadata["short_name"]
-	if !ok {
-		return proofwatch.Evidence{}, errors.New("expected short_name in metadata")
-	}
-
-	checkIdStr, ok := checkId.(string)
-	if !ok {
-		return proofwatch.Evidence{}, errors.New("expected short_name value to be a string")
-	}
-
+// newEvidence attributes a scan to the check that produced it.
+func newEvidence(activity ocsf.ScanActivity, checkID, policyData string) proofwatch.Evidence {
+	action := "observed"
+	actionId := int32(3)
 	policy := ocsf.Policy{
-		Uid:  &checkIdStr,
-		Data: &policyDataStr,
+		Uid:  &checkID,
+		Data: &policyData,
 	}
 
-	evidenceEvent := proofwatch.Evidence{
+	return proofwatch.Evidence{
 		ScanActivity: activity,
 		Policy:       policy,
 		Action:       &action,
 		ActionID:     &actionId,
 	}
-
-	return evidenceEvent, nil
 }
 
 func mapStatus(f ConftestFinding) (string, int32) {
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/bundle"
+	"github.com/open-policy-agent/opa/v1/format"
+	"github.com/open-policy-agent/opa/v1/loader"
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+const (
+	// defaultBundleKeyID is the key ID used when bundle-key-id is not set.
+	defaultBundleKeyID = "default"
+	// defaultBundleSigningAlgorithm matches the OPA default for signed bundles.
+	defaultBundleSigningAlgorithm = "RS256"
+)
+
+var supportedBundleAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// checksNamespace is the top-level document the policies and data of each check are moved
+// under in a bundle, so every check owns its own bundle root.
+const checksNamespace = "checks"
+
+// bundleCheck is the manifest metadata recorded for each check in a bundle.
+type bundleCheck struct {
+	// Roots are the bundle roots that hold the check's policy.
+	Roots []string `json:"roots"`
+	// Data is the path of the check's data document.
+	Data string `json:"data"`
+	// Rules are the OSCAL rule IDs the check produces evidence for.
+	Rules []string `json:"rules"`
+}
+
+// bundleManifest computes the bundle root of every check in the policy and the manifest
+// metadata that links each check to its rule IDs and the assessment plan.
+func (c *Composer) bundleManifest(pl policy.Policy, config Config) ([]string, map[string]any, error) {
+	checks := make(map[string]*bundleCheck)
+	var order []string
+	for _, rule := range pl {
+		for _, check := range rule.Checks {
+			entry, ok := checks[check.ID]
+			if !ok {
+				entry = &bundleCheck{}
+				checks[check.ID] = entry
+				order = append(order, check.ID)
+			}
+			if !slices.Contains(entry.Rules, rule.Rule.ID) {
+				entry.Rules = append(entry.Rules, rule.Rule.ID)
+			}
+		}
+	}
+
+	roots := make([]string, 0, len(order))
+	for _, checkID := range order {
+		root := checksNamespace + "/" + checkID
+		checks[checkID].Roots = []string{root}
+		checks[checkID].Data = root
+		roots = append(roots, root)
+	}
+
+	metadata := map[string]any{"checks": checks}
+	if config.AssessmentPlan != "" {
+		planUUID, err := assessmentPlanUUID(config.AssessmentPlan)
+		if err != nil {
+			return nil, nil, err
+		}
+		metadata["assessment-plan"] = planUUID
+	}
+	sort.Strings(roots)
+	return roots, metadata, nil
+}
+
+// checkNamespace returns the reference a check's packages are moved under in a bundle,
+// data.checks.<check ID>.
+func checkNamespace(checkID string) ast.Ref {
+	return ast.Ref{ast.DefaultRootDocument, ast.StringTerm(checksNamespace), ast.StringTerm(checkID)}
+}
+
+// namespaceChecks moves the policies and data of each check under data.checks.<check ID>,
+// so checks that share a Rego package such as main do not share a bundle root. The check's
+// data document is merged into that root and references to the check's own packages and
+// data are rewritten, so policies written against data.<check ID> keep working.
+func namespaceChecks(b *bundle.Bundle) error {
+	packages := make(map[string][]ast.Ref)
+	for _, module := range b.Modules {
+		checkID, ok := moduleCheckID(module.Path)
+		if !ok || module.Parsed == nil {
+			return fmt.Errorf("bundle module %s is not part of a check", module.Path)
+		}
+		pkg := module.Parsed.Package.Path
+		if !slices.ContainsFunc(packages[checkID], func(ref ast.Ref) bool { return ref.Equal(pkg) }) {
+			packages[checkID] = append(packages[checkID], pkg)
+		}
+	}
+
+	for i := range b.Modules {
+		module := &b.Modules[i]
+		checkID, _ := moduleCheckID(module.Path)
+		namespace := checkNamespace(checkID)
+		data := ast.Ref{ast.DefaultRootDocument, ast.StringTerm(checkID)}
+		transformed, err := ast.TransformRefs(module.Parsed, func(ref ast.Ref) (ast.Value, error) {
+			for _, pkg := range packages[checkID] {
+				if ref.HasPrefix(pkg) {
+					return namespace.Concat(ref[1:]), nil
+				}
+			}
+			if ref.HasPrefix(data) {
+				return namespace.Concat(ref[2:]), nil
+			}
+			return ref, nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to namespace %s: %w", module.Path, err)
+		}
+		module.Parsed = transformed.(*ast.Module)
+
+		version := regoVersion
+		if v, ok := b.Manifest.FileRegoVersions["/"+module.Path]; ok {
+			version = ast.RegoVersionFromInt(v)
+		}
+		if module.Raw, err = format.AstWithOpts(module.Parsed, format.Opts{RegoVersion: version}); err != nil {
+			return fmt.Errorf("failed to namespace %s: %w", module.Path, err)
+		}
+	}
+
+	for checkID := range packages {
+		document, ok := b.Data[checkID].(map[string]any)
+		if !ok {
+			continue
+		}
+		delete(b.Data, checkID)
+		for key, value := range document {
+			setData(b.Data, []string{checksNamespace, checkID, key}, value)
+		}
+	}
+	return nil
+}
+
+// setData sets the document at path in data, creating parents as needed.
+func setData(data map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := data[key].(map[string]any)
+		if !ok {
+			child = make(map[string]any)
+			data[key] = child
+		}
+		data = child
+	}
+	data[path[len(path)-1]] = value
+}
+
+// policyBundle loads the data documents and Rego policies in the policy output directory
+// into a bundle. Each policy is parsed with the Rego version it is written in.
+func (c *Composer) policyBundle(roots []string) (*bundle.Bundle, error) {
+	// Data documents are written inside the policy tree and loaded relative to it.
+	documents, err := loader.NewFileLoader().Filtered([]string{filepath.Join(c.policyOutput, "policy")}, func(abspath string, info fs.FileInfo, depth int) bool {
+		return bundleFilter(abspath, info, depth) || (!info.IsDir() && filepath.Ext(abspath) == ".rego")
+	})
+	if err != nil {
+		return nil, err
+	}
+	modules, err := loadRegoModules(c.policyOutput, false)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bundle.Bundle{Data: documents.Documents}
+	b.SetRegoVersion(regoVersion)
+	b.Manifest.Roots = &roots
+	for _, module := range modules {
+		rel, err := filepath.Rel(c.policyOutput, module.Path)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.ToSlash(rel)
+		b.Modules = append(b.Modules, bundle.ModuleFile{
+			URL:    path,
+			Path:   path,
+			Raw:    module.Raw,
+			Parsed: module.Parsed,
+		})
+		if module.Version != regoVersion {
+			if b.Manifest.FileRegoVersions == nil {
+				b.Manifest.FileRegoVersions = make(map[string]int)
+			}
+			// Versions are keyed by the path from the bundle root.
+			b.Manifest.FileRegoVersions["/"+path] = module.Version.Int()
+			logger.Debug("Bundling Rego file with its own version", "path", path, "rego_version", module.Version.Int())
+		}
+	}
+	return b, nil
+}
+
+// bundleSigningConfig returns the OPA signing configuration, or nil if bundles are not signed.
+func bundleSigningConfig(config Config) *bundle.SigningConfig {
+	if config.BundleSigningKey == "" {
+		return nil
+	}
+	return bundle.NewSigningConfig(config.BundleSigningKey, bundleAlgorithm(config), "")
+}
+
+func bundleAlgorithm(config Config) string {
+	if config.BundleSigningAlgorithm != "" {
+		return config.BundleSigningAlgorithm
+	}
+	return defaultBundleSigningAlgorithm
+}
+
+func bundleKeyID(config Config) string {
+	if config.BundleKeyID != "" {
+		return config.BundleKeyID
+	}
+	return defaultBundleKeyID
+}
+
+// LoadBundle reads a bundle from disk. When a verification key is configured, the
+// bundle must carry a valid signature from that key and every file must match the signed digests.
+func LoadBundle(path string, config Config) (*bundle.Bundle, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bundle.NewReader(file).WithRegoVersion(regoVersion)
+	if config.BundleVerificationKey != "" {
+		key, err := os.ReadFile(filepath.Clean(config.BundleVerificationKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle verification key: %w", err)
+		}
+		keyID := bundleKeyID(config)
+		keys := map[string]*bundle.KeyConfig{
+			keyID: {Key: string(key), Algorithm: bundleAlgorithm(config)},
+		}
+		reader = reader.WithBundleVerificationConfig(bundle.NewVerificationConfig(keys, keyID, "", nil))
+	} else {
+		reader = reader.WithSkipBundleVerification(true)
+	}
+
+	b, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bundle %s: %w", path, err)
+	}
+	return &b, nil
+}
@@ -3,14 +3,16 @@ package server
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/open-policy-agent/opa/v1/compile"
 	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
-	cp "github.com/otiai10/copy"
 )
 
 // Adapted from: https://github.com/complytime/compliance-to-policy-plugins/blob/main/opa-plugin/server/composer.go
@@ -32,17 +34,41 @@ func (c *Composer) GetPoliciesDir() string {
 	return c.policiesTemplates
 }
 
-func (c *Composer) Bundle(ctx context.Context, config Config) error {
+// Bundle builds an OPA bundle from the policy output directory. Each check's policies and
+// data are moved under their own root, data.checks.<check ID>, and the manifest metadata
+// links each check to its rule IDs and the assessment plan. Checks written in Rego v0 are kept as v0 and recorded in the
+// manifest file_rego_versions. The bundle is signed when a signing key is configured.
+func (c *Composer) Bundle(ctx context.Context, pl policy.Policy, config Config) error {
 	buf := bytes.NewBuffer(nil)
 
+	roots, metadata, err := c.bundleManifest(pl, config)
+	if err != nil {
+		return err
+	}
+
+	b, err := c.policyBundle(roots)
+	if err != nil {
+		return err
+	}
+	if err := namespaceChecks(b); err != nil {
+		return err
+	}
+
 	compiler := compile.New().
 		WithRevision(config.BundleRevision).
 		WithOutput(buf).
-		WithPaths(config.PolicyOutput)
+		WithBundle(b).
+		WithMetadata(&metadata)
+
+	if signingConfig := bundleSigningConfig(config); signingConfig != nil {
+		compiler = compiler.
+			WithBundleSigningConfig(signingConfig).
+			WithBundleVerificationKeyID(bundleKeyID(config))
+	}
 
 	compiler = compiler.WithRegoVersion(regoVersion)
 
-	err := compiler.Build(ctx)
+	err = compiler.Build(ctx)
 	if err != nil {
 		return err
 	}
@@ -51,6 +77,7 @@ func (c *Composer) Bundle(ctx context.Context, config Config) error {
 	if err != nil {
 		return err
 	}
+	defer out.Close()
 
 	_, err = io.Copy(out, buf)
 	if err != nil {
@@ -59,29 +86,80 @@ func (c *Composer) Bundle(ctx context.Context, config Config) error {
 	return nil
 }
 
-func (c *Composer) GeneratePolicySet(pl policy.Policy, config Config) error {
-	var local bool
-	outputDir := c.policyOutput
-	if config.PolicyTemplates != "" {
-		local = true
-		outputDir = filepath.Join(c.policyOutput, "policy")
-		if err := os.MkdirAll(outputDir, 0750); err != nil {
-			return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+// bundleFilter keeps check tests and test fixtures out of the bundle. Tests are run by
+// TestPolicySet and may depend on built-ins that are only available when testing, and
+// only data documents written by GenerateData are loaded as bundle data.
+func bundleFilter(abspath string, info fs.FileInfo, _ int) bool {
+	if info.IsDir() {
+		return false
+	}
+	switch filepath.Ext(abspath) {
+	case ".rego":
+		return isTestFile(abspath)
+	case ".json", ".yaml", ".yml":
+		return !isDataFile(info.Name())
+	}
+	return false
+}
+
+// isDataFile reports whether a file name is one OPA loads as a bundle data document.
+func isDataFile(name string) bool {
+	return name == dataFileName || name == "data.yaml"
+}
+
+// GeneratePolicySet writes the policy directory for every check in the policy to
+// the output directory. Policies are copied from local policy templates when configured,
+// otherwise they are fetched from the configured policy source.
+func (c *Composer) GeneratePolicySet(ctx context.Context, pl policy.Policy, config Config) error {
+	var source PolicySource
+	switch {
+	case config.PolicyTemplates != "":
+		source = &dirSource{root: c.policiesTemplates}
+	case config.PolicySource != "":
+		var err error
+		source, err = NewPolicySource(config)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("either policy-templates or policy-source must be provided to generate policies")
+	}
+	defer func() {
+		if err := source.Close(); err != nil {
+			logger.Warn("Failed to clean up policy source", "error", err)
 		}
+	}()
+
+	outputDir := filepath.Join(c.policyOutput, "policy")
+	if err := os.MkdirAll(outputDir, 0750); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
 	}
 
+	seen := make(map[string]struct{})
+	var unresolved []string
 	for _, rule := range pl {
 		for _, check := range rule.Checks {
-			if local {
-				// Copy over the check directory
-				origfilePath := filepath.Join(c.policiesTemplates, check.ID)
-				destfilePath := filepath.Join(outputDir, check.ID)
-				if err := cp.Copy(origfilePath, destfilePath); err != nil {
-					return err
-				}
+			if _, ok := seen[check.ID]; ok {
+				continue
+			}
+			seen[check.ID] = struct{}{}
+
+			if err := validateCheckID(check.ID); err != nil {
+				return err
+			}
+			destfilePath := filepath.Join(outputDir, check.ID)
+			err := source.Fetch(ctx, check.ID, destfilePath)
+			switch {
+			case errors.Is(err, errCheckNotFound):
+				unresolved = append(unresolved, check.ID)
+			case err != nil:
+				return fmt.Errorf("failed to fetch policy for check %s: %w", check.ID, err)
 			}
 		}
 	}
 
+	if len(unresolved) > 0 {
+		return fmt.Errorf("policies could not be resolved for check IDs: %s", strings.Join(unresolved, ", "))
+	}
 	return nil
 }
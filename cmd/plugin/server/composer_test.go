@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+	"github.com/oscal-compass/oscal-sdk-go/extensions"
+)
+
+// testPolicy returns a policy with a rule per check ID.
+func testPolicy(checkIDs ...string) policy.Policy {
+	var pl policy.Policy
+	for _, checkID := range checkIDs {
+		pl = append(pl, extensions.RuleSet{
+			Rule:   extensions.Rule{ID: "rule-" + checkID},
+			Checks: []extensions.Check{{ID: checkID}},
+		})
+	}
+	return pl
+}
+
+func TestGeneratePolicySet(t *testing.T) {
+	templates := t.TempDir()
+	writeFiles(t, templates, map[string]string{
+		"check-1/policy.rego": "package main\n",
+		"secret/policy.rego":  "package secret\n",
+	})
+
+	tests := []struct {
+		name     string
+		checkIDs []string
+		want     map[string]string
+		wantErr  string
+	}{
+		{
+			name:     "copies each check",
+			checkIDs: []string{"check-1", "check-1"},
+			want:     map[string]string{"policy/check-1/policy.rego": "package main\n"},
+		},
+		{
+			name:     "unresolved check",
+			checkIDs: []string{"check-1", "check-2"},
+			wantErr:  "policies could not be resolved for check IDs: check-2",
+		},
+		{
+			name:     "check ID outside the output directory",
+			checkIDs: []string{"../secret"},
+			wantErr:  "invalid check ID",
+		},
+		{
+			name:     "check ID naming the output directory",
+			checkIDs: []string{".."},
+			wantErr:  "invalid check ID",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "out")
+			composer := NewComposer(templates, output)
+			err := composer.GeneratePolicySet(context.Background(), testPolicy(tt.checkIDs...), Config{PolicyTemplates: templates})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("GeneratePolicySet() error = %v, want %q", err, tt.wantErr)
+				}
+				if _, err := os.Stat(filepath.Join(output, "secret")); err == nil {
+					t.Error("GeneratePolicySet() wrote a check outside the policy directory")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GeneratePolicySet() error = %v", err)
+			}
+			if got := readFiles(t, output); !equalFiles(got, tt.want) {
+				t.Errorf("GeneratePolicySet() wrote %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
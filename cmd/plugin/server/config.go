@@ -3,24 +3,94 @@ package server
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	// Required
 	PolicyResults string `mapstructure:"policy-results"`
 
+	// Optional source of evidence for GetResults: loki (default), local, or conftest
+	EvidenceSource string `mapstructure:"evidence-source"`
+
 	// Optionally bundle local policy
 	Bundle         string `mapstructure:"bundle"`
 	BundleRevision string `mapstructure:"bundle-revision"`
 
+	// Optionally sign the bundle and verify its signature when loaded
+	BundleSigningKey       string `mapstructure:"bundle-signing-key"`
+	BundleSigningAlgorithm string `mapstructure:"bundle-signing-alg"`
+	BundleVerificationKey  string `mapstructure:"bundle-verification-key"`
+	BundleKeyID            string `mapstructure:"bundle-key-id"`
+
 	// Optional if building locally
 	PolicyTemplates string `mapstructure:"policy-templates"`
 	PolicyOutput    string `mapstructure:"policy-output"`
 
+	// Optionally rewrite Rego v0 checks to v1 in the policy output
+	UpgradeRego string `mapstructure:"upgrade-rego"`
+
+	// Optionally run the Rego tests of each check before bundling
+	TestPolicies string `mapstructure:"test-policies"`
+	MinCoverage  string `mapstructure:"min-coverage"`
+
+	// Optional remote policy source used when policy-templates is not set
+	PolicySource         string `mapstructure:"policy-source"`
+	PolicySourceDigest   string `mapstructure:"policy-source-digest"`
+	PolicySourceUsername string `mapstructure:"policy-source-username"`
+	PolicySourcePassword string `mapstructure:"policy-source-password"`
+
+	// Optionally verify the assessment plan signature before use
+	AssessmentPlan string `mapstructure:"assessment-plan"`
+	VerifyPlan     string `mapstructure:"verify-plan"`
+	PlanSignature  string `mapstructure:"plan-signature"`
+	// Optional mapping of evidence labels to the subjects declared in the assessment plan
+	SubjectMapping string `mapstructure:"subject-mapping"`
+
+	// Optional assessment window for evidence queries, defaulting to the assessment plan period
+	EvidenceStart string `mapstructure:"evidence-start"`
+	EvidenceEnd   string `mapstructure:"evidence-end"`
+	// Optional number of checks queried at the same time
+	EvidenceConcurrency string `mapstructure:"evidence-concurrency"`
+	// Optional path of an archive that GetResults writes the fetched evidence to
+	EvidenceSnapshot string `mapstructure:"evidence-snapshot"`
+	// Optionally check the evidence source on Configure, or only log the queries GetResults would run
+	HealthCheck string `mapstructure:"health-check"`
+	DryRun      string `mapstructure:"dry-run"`
+
 	// Loki Client Config
 	LokiURL string `mapstructure:"loki-url"`
+	// Optional order and page size used to page through Loki results in the window
+	LokiDirection string `mapstructure:"loki-direction"`
+	LokiPageSize  string `mapstructure:"loki-page-size"`
+	// Optional mapping of the labels that hold check results in Loki
+	LokiStreamSelector string `mapstructure:"loki-stream-selector"`
+	LokiCheckField     string `mapstructure:"loki-check-field"`
+	LokiStatusField    string `mapstructure:"loki-status-field"`
+	LokiStatusValues   string `mapstructure:"loki-status-values"`
+	LokiReasonField    string `mapstructure:"loki-reason-field"`
+	// Optional retries of rate limited or failed Loki requests and a limit on requests per run
+	LokiMaxRetries   string `mapstructure:"loki-max-retries"`
+	LokiRetryBackoff string `mapstructure:"loki-retry-backoff"`
+	LokiQueryBudget  string `mapstructure:"loki-query-budget"`
+	// Optional authentication for self-hosted Loki: a bearer token, mTLS, custom headers, and tenants
+	LokiBearerToken     string `mapstructure:"loki-bearer-token"`
+	LokiBearerTokenFile string `mapstructure:"loki-bearer-token-file"`
+	LokiClientCert      string `mapstructure:"loki-client-cert"`
+	LokiClientKey       string `mapstructure:"loki-client-key"`
+	LokiCACert          string `mapstructure:"loki-ca-cert"`
+	LokiHeaders         string `mapstructure:"loki-headers"`
+	LokiOrgID           string `mapstructure:"loki-org-id"`
+
+	// Optional Grafana instance and Loki data source used for Explore evidence links
+	GrafanaURL           string `mapstructure:"grafana-url"`
+	GrafanaDatasourceUID string `mapstructure:"grafana-datasource-uid"`
 
 	// Grafana Cloud Config
 	GrafanaCloudEndpoint   string `mapstructure:"grafana-cloud-endpoint"`
@@ -40,6 +110,32 @@ func (c *Config) LoadFromEnv() {
 	if c.GrafanaCloudAPIKey == "" {
 		c.GrafanaCloudAPIKey = os.Getenv("GRAFANA_CLOUD_API_KEY")
 	}
+
+	// Load the Loki bearer token unless a token file or Grafana Cloud basic auth is configured
+	if c.LokiBearerToken == "" && c.LokiBearerTokenFile == "" && c.GrafanaCloudEndpoint == "" {
+		c.LokiBearerToken = os.Getenv("LOKI_BEARER_TOKEN")
+	}
+
+	// Load the assessment plan passed to c2pcli so the plugin reads the same plan
+	if c.AssessmentPlan == "" {
+		c.AssessmentPlan = os.Getenv("ASSESSMENT_PLAN")
+	}
+
+	// Load the plan verification key so it can come from the same secret store as the signing key
+	if c.VerifyPlan == "" {
+		c.VerifyPlan = os.Getenv("VERIFY_PLAN")
+	}
+	if c.PlanSignature == "" {
+		c.PlanSignature = os.Getenv("PLAN_SIGNATURE")
+	}
+
+	// Load policy source credentials from environment variables
+	if c.PolicySourceUsername == "" {
+		c.PolicySourceUsername = os.Getenv("POLICY_SOURCE_USERNAME")
+	}
+	if c.PolicySourcePassword == "" {
+		c.PolicySourcePassword = os.Getenv("POLICY_SOURCE_PASSWORD")
+	}
 }
 
 func (c *Config) Validate() error {
@@ -56,11 +152,151 @@ func (c *Config) Validate() error {
 		if err := checkPath(&c.PolicyTemplates); err != nil {
 			errs = append(errs, err)
 		}
+	} else if c.PolicySource != "" {
+		if err := checkPath(&c.PolicyOutput); err != nil {
+			errs = append(errs, err)
+		}
+
+		if _, err := NewPolicySource(*c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.BundleSigningKey != "" && c.Bundle == "" {
+		errs = append(errs, errors.New("bundle must be provided when using bundle-signing-key"))
+	}
+	if err := checkPath(&c.BundleSigningKey); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkPath(&c.BundleVerificationKey); err != nil {
+		errs = append(errs, err)
+	}
+	if c.BundleSigningAlgorithm != "" && !slices.Contains(supportedBundleAlgorithms, c.BundleSigningAlgorithm) {
+		errs = append(errs, fmt.Errorf("unsupported bundle-signing-alg %q: must be one of %s", c.BundleSigningAlgorithm, strings.Join(supportedBundleAlgorithms, ", ")))
+	}
+
+	if c.UpgradeRego != "" {
+		if _, err := strconv.ParseBool(c.UpgradeRego); err != nil {
+			errs = append(errs, fmt.Errorf("invalid upgrade-rego value %q: %w", c.UpgradeRego, err))
+		}
+	}
+	if c.TestPolicies != "" {
+		if _, err := strconv.ParseBool(c.TestPolicies); err != nil {
+			errs = append(errs, fmt.Errorf("invalid test-policies value %q: %w", c.TestPolicies, err))
+		}
+	}
+	if c.MinCoverage != "" {
+		if coverage, err := strconv.ParseFloat(c.MinCoverage, 64); err != nil || coverage < 0 || coverage > 100 {
+			errs = append(errs, fmt.Errorf("invalid min-coverage value %q: must be a percentage between 0 and 100", c.MinCoverage))
+		}
+	}
+
+	if plan := os.Getenv("ASSESSMENT_PLAN"); plan != "" && c.AssessmentPlan != "" && filepath.Clean(plan) != filepath.Clean(c.AssessmentPlan) {
+		errs = append(errs, fmt.Errorf("assessment-plan %s does not match the ASSESSMENT_PLAN %s used for the run", c.AssessmentPlan, plan))
+	}
+	if c.VerifyPlan != "" {
+		if c.AssessmentPlan == "" {
+			errs = append(errs, errors.New("assessment-plan must be provided when using verify-plan"))
+		}
+		if err := checkPath(&c.AssessmentPlan); err != nil {
+			errs = append(errs, err)
+		}
+		if err := checkPath(&c.VerifyPlan); err != nil {
+			errs = append(errs, err)
+		}
+		if err := checkPath(&c.PlanSignature); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if _, err := newSubjectMapper(*c); err != nil {
+		errs = append(errs, err)
 	}
 
-	// Validate Loki configuration
-	if c.LokiURL == "" && c.GrafanaCloudEndpoint == "" {
-		errs = append(errs, errors.New("either loki-url or grafana-cloud-endpoint must be provided"))
+	switch c.EvidenceSource {
+	case "", evidenceSourceLoki:
+		// Validate Loki configuration
+		if c.LokiURL == "" && c.GrafanaCloudEndpoint == "" {
+			errs = append(errs, errors.New("either loki-url or grafana-cloud-endpoint must be provided"))
+		}
+	case evidenceSourceLocal:
+		if c.PolicyResults == "" {
+			errs = append(errs, errors.New("policy-results must be provided when evidence-source is local"))
+		}
+		if c.Bundle == "" && c.PolicyOutput == "" {
+			errs = append(errs, errors.New("either bundle or policy-output must be provided when evidence-source is local"))
+		}
+	case evidenceSourceConftest:
+		if c.PolicyResults == "" {
+			errs = append(errs, errors.New("policy-results must be provided when evidence-source is conftest"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported evidence-source %q: must be %s, %s, or %s", c.EvidenceSource, evidenceSourceLoki, evidenceSourceLocal, evidenceSourceConftest))
+	}
+
+	if c.EvidenceStart != "" || c.EvidenceEnd != "" {
+		if _, err := evidenceWindow(*c, time.Now()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.LokiDirection != "" && c.LokiDirection != lokiDirectionBackward && c.LokiDirection != lokiDirectionForward {
+		errs = append(errs, fmt.Errorf("unsupported loki-direction %q: must be %s or %s", c.LokiDirection, lokiDirectionBackward, lokiDirectionForward))
+	}
+	if _, err := newLokiLabelMapping(*c); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := newLokiAuth(*c); err != nil {
+		errs = append(errs, err)
+	}
+	if c.EvidenceConcurrency != "" {
+		if n, err := strconv.Atoi(c.EvidenceConcurrency); err != nil || n <= 0 {
+			errs = append(errs, fmt.Errorf("invalid evidence-concurrency value %q: must be a positive integer", c.EvidenceConcurrency))
+		}
+	}
+	if c.HealthCheck != "" {
+		if _, err := strconv.ParseBool(c.HealthCheck); err != nil {
+			errs = append(errs, fmt.Errorf("invalid health-check value %q: %w", c.HealthCheck, err))
+		}
+	}
+	if c.DryRun != "" {
+		if _, err := strconv.ParseBool(c.DryRun); err != nil {
+			errs = append(errs, fmt.Errorf("invalid dry-run value %q: %w", c.DryRun, err))
+		}
+	}
+	if c.EvidenceSnapshot != "" {
+		dir := filepath.Dir(c.EvidenceSnapshot)
+		if err := checkPath(&dir); err != nil {
+			errs = append(errs, fmt.Errorf("invalid evidence-snapshot: %w", err))
+		}
+	}
+	if c.LokiMaxRetries != "" {
+		if n, err := strconv.Atoi(c.LokiMaxRetries); err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("invalid loki-max-retries value %q: must be a non-negative integer", c.LokiMaxRetries))
+		}
+	}
+	if c.LokiRetryBackoff != "" {
+		if d, err := time.ParseDuration(c.LokiRetryBackoff); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("invalid loki-retry-backoff value %q: must be a positive duration such as 500ms or 2s", c.LokiRetryBackoff))
+		}
+	}
+	if c.LokiQueryBudget != "" {
+		if n, err := strconv.Atoi(c.LokiQueryBudget); err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("invalid loki-query-budget value %q: must be a non-negative integer", c.LokiQueryBudget))
+		}
+	}
+	if c.LokiPageSize != "" {
+		if size, err := strconv.Atoi(c.LokiPageSize); err != nil || size <= 0 {
+			errs = append(errs, fmt.Errorf("invalid loki-page-size value %q: must be a positive integer", c.LokiPageSize))
+		}
+	}
+
+	if (c.GrafanaURL == "") != (c.GrafanaDatasourceUID == "") {
+		errs = append(errs, errors.New("grafana-url and grafana-datasource-uid must be provided together"))
+	}
+	if c.GrafanaURL != "" {
+		if u, err := url.Parse(c.GrafanaURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid grafana-url %q: must be an absolute URL", c.GrafanaURL))
+		}
 	}
 
 	// Validate Grafana Cloud authentication
@@ -73,6 +309,83 @@ func (c *Config) Validate() error {
 	return errors.Join(errs...)
 }
 
+// LokiQueryDirection returns the order in which Loki results are paged, backward by default.
+func (c *Config) LokiQueryDirection() string {
+	if c.LokiDirection == "" {
+		return lokiDirectionBackward
+	}
+	return c.LokiDirection
+}
+
+// LokiQueryPageSize returns the number of Loki entries requested per page.
+func (c *Config) LokiQueryPageSize() int {
+	if size, err := strconv.Atoi(c.LokiPageSize); err == nil && size > 0 {
+		return size
+	}
+	return defaultLokiPageSize
+}
+
+// EvidenceQueryConcurrency returns the number of checks queried at the same time.
+func (c *Config) EvidenceQueryConcurrency() int {
+	if n, err := strconv.Atoi(c.EvidenceConcurrency); err == nil && n > 0 {
+		return n
+	}
+	return defaultEvidenceConcurrency
+}
+
+// LokiMaxRetryCount returns how many times a failed Loki request is retried.
+func (c *Config) LokiMaxRetryCount() int {
+	if n, err := strconv.Atoi(c.LokiMaxRetries); err == nil && n >= 0 {
+		return n
+	}
+	return defaultLokiMaxRetries
+}
+
+// LokiRetryBackoffDuration returns the wait before the first retry of a Loki request.
+func (c *Config) LokiRetryBackoffDuration() time.Duration {
+	if d, err := time.ParseDuration(c.LokiRetryBackoff); err == nil && d > 0 {
+		return d
+	}
+	return defaultLokiRetryBackoff
+}
+
+// LokiQueryBudgetLimit returns the maximum number of Loki requests per run, or zero if unlimited.
+func (c *Config) LokiQueryBudgetLimit() int {
+	n, _ := strconv.Atoi(c.LokiQueryBudget)
+	return max(n, 0)
+}
+
+// HealthCheckEnabled reports whether Configure should check the evidence source.
+func (c *Config) HealthCheckEnabled() bool {
+	enabled, _ := strconv.ParseBool(c.HealthCheck)
+	return enabled
+}
+
+// DryRunEnabled reports whether GetResults should only log the evidence queries.
+func (c *Config) DryRunEnabled() bool {
+	enabled, _ := strconv.ParseBool(c.DryRun)
+	return enabled
+}
+
+// RegoUpgradeEnabled reports whether v0 checks should be rewritten to v1.
+func (c *Config) RegoUpgradeEnabled() bool {
+	enabled, _ := strconv.ParseBool(c.UpgradeRego)
+	return enabled
+}
+
+// PolicyTestsEnabled reports whether check tests should run before bundling.
+// Setting a minimum coverage enables tests.
+func (c *Config) PolicyTestsEnabled() bool {
+	enabled, _ := strconv.ParseBool(c.TestPolicies)
+	return enabled || c.MinCoverage != ""
+}
+
+// MinCoveragePercent returns the configured minimum coverage, or zero if unset.
+func (c *Config) MinCoveragePercent() float64 {
+	coverage, _ := strconv.ParseFloat(c.MinCoverage, 64)
+	return coverage
+}
+
 func checkPath(path *string) error {
 	if path != nil && *path != "" {
 		cleanedPath := filepath.Clean(*path)
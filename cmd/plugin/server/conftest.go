@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+const evidenceSourceConftest = "conftest"
+
+// conftestFinding is a single warning, failure, or exception in Conftest JSON output.
+type conftestFinding struct {
+	Message  string         `json:"msg"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+// checkID returns the short_name metadata that identifies the check that produced the finding.
+func (f conftestFinding) checkID() string {
+	shortName, _ := f.Metadata["short_name"].(string)
+	return shortName
+}
+
+// conftestFileResult is the result for one file and namespace in `conftest test --output json`.
+// It matches the ConftestFileResult parsed by conftest-exporter.
+type conftestFileResult struct {
+	FileName   string            `json:"filename"`
+	Namespace  string            `json:"namespace"`
+	Successes  int               `json:"successes"`
+	Warnings   []conftestFinding `json:"warnings"`
+	Failures   []conftestFinding `json:"failures"`
+	Exceptions []conftestFinding `json:"exceptions"`
+}
+
+// conftestSource is an EvidenceSource that reads the Conftest JSON output files in the
+// policy results directory. Findings are attributed to checks by their short_name metadata.
+// A check with no findings for a file passes if the file was evaluated against the check's
+// namespace. The output files are read on the first query.
+type conftestSource struct {
+	config      Config
+	once        sync.Once
+	fileResults []conftestFileResult
+	namespaces  map[string][]string
+	err         error
+}
+
+func (c *conftestSource) Name() string {
+	return evidenceSourceConftest
+}
+
+// Query returns a record per file result for the check. The window is ignored because
+// Conftest output is not timestamped.
+func (c *conftestSource) Query(_ context.Context, checkID string, _ TimeWindow) ([]EvidenceRecord, error) {
+	c.once.Do(c.load)
+	if c.err != nil {
+		return nil, c.err
+	}
+	var records []EvidenceRecord
+	for _, fileResult := range c.fileResults {
+		if record, ok := conftestRecord(fileResult, checkID, c.namespaces); ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (c *conftestSource) missingEvidenceReason(checkID string) string {
+	return fmt.Sprintf("No Conftest results in %s were evaluated against the policies of %s", c.config.PolicyResults, checkID)
+}
+
+func (c *conftestSource) load() {
+	c.fileResults, c.err = loadConftestResults(c.config.PolicyResults)
+	if c.err != nil {
+		c.err = fmt.Errorf("failed to read Conftest results from %s: %w", c.config.PolicyResults, c.err)
+		return
+	}
+	if len(c.fileResults) == 0 {
+		logger.Warn("No Conftest results found", "path", c.config.PolicyResults)
+	}
+	c.namespaces = checkNamespaces(c.config)
+}
+
+// conftestRecord returns the record for a check in one file result. It returns false
+// if the file result has no findings for the check and was not evaluated against the
+// check's namespace. If the check namespaces are unknown, every file result is assumed
+// to have evaluated the check.
+func conftestRecord(fileResult conftestFileResult, checkID string, namespaces map[string][]string) (EvidenceRecord, bool) {
+	failures := findingMessages(fileResult.Failures, checkID)
+	warnings := findingMessages(fileResult.Warnings, checkID)
+	exceptions := findingMessages(fileResult.Exceptions, checkID)
+
+	record := EvidenceRecord{
+		CheckID:      checkID,
+		Timestamp:    time.Now(),
+		SubjectID:    fileResult.FileName,
+		SubjectTitle: fileResult.FileName,
+		Attributes:   map[string]string{"fileName": fileResult.FileName, "namespace": fileResult.Namespace},
+		Props: []policy.Property{
+			{Name: "namespace", Value: fileResult.Namespace},
+			{Name: "successes", Value: fmt.Sprintf("%d", fileResult.Successes)},
+		},
+	}
+	switch {
+	case len(failures) > 0:
+		record.Result = policy.ResultFail
+		record.Reason = strings.Join(append(failures, warnings...), "; ")
+	case len(warnings) > 0:
+		record.Result = policy.ResultWarning
+		record.Reason = strings.Join(warnings, "; ")
+	case len(exceptions) > 0:
+		record.Result = policy.ResultPass
+		record.Reason = "Excepted: " + strings.Join(exceptions, "; ")
+	default:
+		if namespaces != nil && !slices.Contains(namespaces[checkID], fileResult.Namespace) {
+			return record, false
+		}
+		if fileResult.Successes == 0 {
+			return record, false
+		}
+		record.Result = policy.ResultPass
+		record.Reason = "No violations found"
+	}
+	if len(exceptions) > 0 {
+		record.Props = append(record.Props, policy.Property{Name: "exceptions", Value: fmt.Sprintf("%d", len(exceptions))})
+	}
+	if raw, err := json.Marshal(fileResult); err == nil {
+		record.Raw = raw
+	}
+	return record, true
+}
+
+func findingMessages(findings []conftestFinding, checkID string) []string {
+	var messages []string
+	for _, finding := range findings {
+		if finding.checkID() == checkID {
+			messages = append(messages, finding.Message)
+		}
+	}
+	return messages
+}
+
+// loadConftestResults reads every Conftest JSON output file under dir. JSON files that
+// are not Conftest output are skipped.
+func loadConftestResults(dir string) ([]conftestFileResult, error) {
+	var results []conftestFileResult
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.ToLower(filepath.Ext(path)) != ".json" {
+			return nil
+		}
+		content, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		var fileResults []conftestFileResult
+		if err := json.Unmarshal(content, &fileResults); err != nil {
+			logger.Warn("Skipping file that is not Conftest JSON output", "path", path, "error", err)
+			return nil
+		}
+		results = append(results, fileResults...)
+		return nil
+	})
+	return results, err
+}
+
+// checkNamespaces returns the Rego packages of each check from the configured bundle or
+// policy output, or nil if neither is available.
+func checkNamespaces(config Config) map[string][]string {
+	if config.Bundle == "" && config.PolicyOutput == "" {
+		return nil
+	}
+	if config.Bundle != "" {
+		if _, err := os.Stat(config.Bundle); err != nil {
+			return nil
+		}
+	}
+	evaluator, err := newLocalEvaluator(config)
+	if err != nil {
+		logger.Warn("Unable to determine check namespaces, attributing all results to every check", "error", err)
+		return nil
+	}
+	if len(evaluator.packages) == 0 {
+		return nil
+	}
+	// Bundles move each check's packages under data.checks.<check ID>, while Conftest
+	// reports the package the policy declares.
+	namespaces := make(map[string][]string, len(evaluator.packages))
+	for checkID, packages := range evaluator.packages {
+		prefix := strings.TrimPrefix(checkNamespace(checkID).String(), "data.") + "."
+		for _, pkg := range packages {
+			namespaces[checkID] = append(namespaces[checkID], strings.TrimPrefix(pkg, prefix))
+		}
+	}
+	return namespaces
+}
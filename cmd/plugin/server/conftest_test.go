@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+func TestConftestSourceShortNameAttribution(t *testing.T) {
+	finding := func(checkID, message string) conftestFinding {
+		return conftestFinding{Message: message, Metadata: map[string]any{"short_name": checkID}}
+	}
+	// Both checks declare package main, and check-2 also declares package helpers, so the
+	// namespace alone cannot tell their findings apart.
+	fileResults := []conftestFileResult{
+		{
+			FileName:  "branches.json",
+			Namespace: "main",
+			Successes: 2,
+			Failures:  []conftestFinding{finding("check-1", "unprotected")},
+			Warnings:  []conftestFinding{finding("check-2", "stale reviews")},
+		},
+		{
+			FileName:  "branches.json",
+			Namespace: "helpers",
+			Successes: 1,
+			Failures:  []conftestFinding{finding("check-1", "reported from another namespace")},
+		},
+		{
+			FileName:   "plan.json",
+			Namespace:  "main",
+			Successes:  3,
+			Exceptions: []conftestFinding{finding("check-2", "accepted risk")},
+		},
+		{
+			FileName:  "untracked.json",
+			Namespace: "main",
+			Failures:  []conftestFinding{{Message: "no short name"}},
+		},
+	}
+	data, err := json.Marshal(fileResults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"results/output.json":                 string(data),
+		"output/policy/check-1/policy.rego":   "package main\n",
+		"output/policy/check-2/policy.rego":   "package main\n",
+		"output/policy/check-2/helpers.rego":  "package helpers\n",
+		"output/policy/check-2/ignored.txt":   "not a policy\n",
+		"results/not-conftest/unrelated.json": `{"not": "conftest"}`,
+	})
+	source := &conftestSource{config: Config{PolicyResults: filepath.Join(dir, "results"), PolicyOutput: filepath.Join(dir, "output")}}
+
+	type want struct {
+		file   string
+		result policy.Result
+		reason string
+	}
+	tests := []struct {
+		checkID string
+		want    []want
+	}{
+		{
+			checkID: "check-1",
+			want: []want{
+				{file: "branches.json", result: policy.ResultFail, reason: "unprotected"},
+				{file: "branches.json", result: policy.ResultFail, reason: "reported from another namespace"},
+				{file: "plan.json", result: policy.ResultPass, reason: "No violations found"},
+			},
+		},
+		{
+			checkID: "check-2",
+			want: []want{
+				{file: "branches.json", result: policy.ResultWarning, reason: "stale reviews"},
+				{file: "branches.json", result: policy.ResultPass, reason: "No violations found"},
+				{file: "plan.json", result: policy.ResultPass, reason: "Excepted: accepted risk"},
+			},
+		},
+		{checkID: "check-3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.checkID, func(t *testing.T) {
+			records, err := source.Query(context.Background(), tt.checkID, TimeWindow{})
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("Query() returned %d records, want %d: %+v", len(records), len(tt.want), records)
+			}
+			for i, want := range tt.want {
+				if got := records[i]; got.SubjectID != want.file || got.Result != want.result || got.Reason != want.reason {
+					t.Errorf("record %d = %s %s (%s), want %s %s (%s)", i, got.SubjectID, got.Result, got.Reason, want.file, want.result, want.reason)
+				}
+			}
+		})
+	}
+}
+
+func TestNoEvidenceObservation(t *testing.T) {
+	loki, err := newLokiSource(Config{LokiURL: "http://localhost:3100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := filepath.Join("policy", "results")
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		source EvidenceSource
+		window TimeWindow
+		want   string
+	}{
+		{
+			name:   "loki default window",
+			source: loki,
+			want:   "No evidence collected from loki within the default query window",
+		},
+		{
+			name:   "loki window",
+			source: loki,
+			window: TimeWindow{Start: start},
+			want:   "No evidence collected from loki since 2026-01-02T03:04:05Z",
+		},
+		{
+			name:   "local",
+			source: &localSource{config: Config{PolicyResults: results}},
+			window: TimeWindow{Start: start},
+			want:   "No input documents in policy/results apply to the policies of check-1",
+		},
+		{
+			name:   "conftest",
+			source: &conftestSource{config: Config{PolicyResults: results}},
+			want:   "No Conftest results in policy/results were evaluated against the policies of check-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			observation := noEvidenceObservation(tt.source, "check-1", tt.window)
+			if len(observation.Subjects) != 1 {
+				t.Fatalf("noEvidenceObservation() has %d subjects, want 1", len(observation.Subjects))
+			}
+			subject := observation.Subjects[0]
+			if subject.Result != policy.ResultError || subject.Reason != tt.want {
+				t.Errorf("noEvidenceObservation() = %s (%s), want %s (%s)", subject.Result, subject.Reason, policy.ResultError, tt.want)
+			}
+		})
+	}
+}
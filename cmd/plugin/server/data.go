@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+// dataFileName is the name OPA uses to load data documents from bundles.
+const dataFileName = "data.json"
+
+var packagePattern = regexp.MustCompile(`^package\s+([A-Za-z_][A-Za-z0-9_.]*)`)
+
+// checkData is the data document written for each check. It is loaded
+// at data.<check ID> so Rego can reference tailored parameters.
+type checkData struct {
+	// Parameters holds the resolved value of every parameter across the check's rules.
+	Parameters map[string]any `json:"parameters"`
+	// Rules holds the resolved parameter values keyed by rule ID.
+	Rules map[string]map[string]any `json:"rules"`
+}
+
+// GenerateData writes a data document with the resolved rule parameters for every check
+// in the policy. Each document is written to policy/<check ID>/data.json in the policy
+// output directory, inside the policy tree that is pushed for Conftest, so it is loaded at
+// data.<check ID> alongside the policies.
+func (c *Composer) GenerateData(pl policy.Policy) error {
+	documents := make(map[string]*checkData)
+	var order []string
+	for _, rule := range pl {
+		for _, check := range rule.Checks {
+			doc, ok := documents[check.ID]
+			if !ok {
+				doc = &checkData{
+					Parameters: make(map[string]any),
+					Rules:      make(map[string]map[string]any),
+				}
+				documents[check.ID] = doc
+				order = append(order, check.ID)
+			}
+			ruleParams := make(map[string]any, len(rule.Rule.Parameters))
+			for _, param := range rule.Rule.Parameters {
+				value := parameterValue(param.Value)
+				ruleParams[param.ID] = value
+				doc.Parameters[param.ID] = value
+			}
+			doc.Rules[rule.Rule.ID] = ruleParams
+		}
+	}
+
+	packages := make(map[string][]string, len(order))
+	for _, checkID := range order {
+		checkPackages, err := checkPackages(filepath.Join(c.policyOutput, "policy", checkID))
+		if err != nil {
+			return fmt.Errorf("failed to determine Rego package for check %s: %w", checkID, err)
+		}
+		if len(checkPackages) == 0 {
+			return fmt.Errorf("no Rego package found for check %s", checkID)
+		}
+		packages[checkID] = checkPackages
+	}
+	if err := checkDataConflicts(packages); err != nil {
+		return err
+	}
+
+	for _, checkID := range order {
+		data, err := json.MarshalIndent(documents[checkID], "", "  ")
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(c.policyOutput, "policy", checkID, dataFileName)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return err
+		}
+		logger.Debug("Wrote check data", "check_id", checkID, "path", "data."+checkID)
+	}
+	return nil
+}
+
+// checkDataConflicts returns an error if a check's data document would share a path with a
+// policy. With Conftest, every check is loaded into one namespace, so a check ID must not
+// be the first segment of any package. In a bundle, the data document and the check's
+// packages share data.checks.<check ID>, so a package must not start with a data field.
+func checkDataConflicts(packages map[string][]string) error {
+	checkIDs := make([]string, 0, len(packages))
+	for checkID := range packages {
+		checkIDs = append(checkIDs, checkID)
+	}
+	sort.Strings(checkIDs)
+
+	var errs []error
+	for _, checkID := range checkIDs {
+		for _, other := range checkIDs {
+			for _, pkg := range packages[other] {
+				if root, _, _ := strings.Cut(pkg, "."); root == checkID {
+					errs = append(errs, fmt.Errorf("data for check %s conflicts with package %s of check %s", checkID, pkg, other))
+				}
+			}
+		}
+		for _, pkg := range packages[checkID] {
+			if root, _, _ := strings.Cut(pkg, "."); root == "parameters" || root == "rules" {
+				errs = append(errs, fmt.Errorf("package %s of check %s conflicts with its data document", pkg, checkID))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkPackages returns the distinct Rego packages declared by the non-test policy files
+// in the check directory.
+func checkPackages(checkDir string) ([]string, error) {
+	seen := make(map[string]struct{})
+	err := filepath.WalkDir(checkDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".rego" || strings.HasSuffix(path, "_test.rego") {
+			return nil
+		}
+		pkg, err := regoPackage(path)
+		if err != nil {
+			return err
+		}
+		if pkg != "" {
+			seen[pkg] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]string, 0, len(seen))
+	for pkg := range seen {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+	return packages, nil
+}
+
+// regoPackage returns the package declared in a Rego file.
+func regoPackage(path string) (string, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if match := packagePattern.FindStringSubmatch(strings.TrimSpace(scanner.Text())); match != nil {
+			return match[1], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// parameterValue converts a parameter value to a typed JSON value so numeric and
+// boolean thresholds can be compared in Rego. Other values are kept as strings.
+func parameterValue(value string) any {
+	var typed any
+	if err := json.Unmarshal([]byte(value), &typed); err == nil {
+		switch typed.(type) {
+		case float64, bool:
+			return typed
+		}
+	}
+	return value
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+	"github.com/oscal-compass/oscal-sdk-go/extensions"
+)
+
+// reviewPolicy requires the number of reviews set by the required_reviews parameter.
+const reviewPolicy = `package main
+
+import rego.v1
+
+deny contains msg if {
+	input.reviews < data["min-reviews"].parameters.required_reviews
+	msg := sprintf("%d reviews required", [data["min-reviews"].rules["rule-reviews"].required_reviews])
+}
+`
+
+// parameterizedPolicy returns a policy with the min-reviews check parameterized by
+// required_reviews and an unparameterized check sharing its package.
+func parameterizedPolicy() policy.Policy {
+	return policy.Policy{
+		{
+			Rule: extensions.Rule{
+				ID:         "rule-reviews",
+				Parameters: []extensions.Parameter{{ID: "required_reviews", Value: "2"}},
+			},
+			Checks: []extensions.Check{{ID: "min-reviews"}},
+		},
+		{
+			Rule:   extensions.Rule{ID: "rule-other"},
+			Checks: []extensions.Check{{ID: "other"}},
+		},
+	}
+}
+
+func TestGenerateData(t *testing.T) {
+	output := t.TempDir()
+	writeFiles(t, output, map[string]string{
+		"policy/min-reviews/policy.rego": reviewPolicy,
+		"policy/other/policy.rego":       "package main\n\nimport rego.v1\n\nwarn contains \"other\" if false\n",
+	})
+	composer := NewComposer("", output)
+	pl := parameterizedPolicy()
+	if err := composer.GenerateData(pl); err != nil {
+		t.Fatalf("GenerateData() error = %v", err)
+	}
+
+	files := readFiles(t, filepath.Join(output, "policy"))
+	var got checkData
+	if err := json.Unmarshal([]byte(files["min-reviews/data.json"]), &got); err != nil {
+		t.Fatalf("min-reviews/data.json: %v", err)
+	}
+	want := checkData{
+		Parameters: map[string]any{"required_reviews": float64(2)},
+		Rules:      map[string]map[string]any{"rule-reviews": {"required_reviews": float64(2)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("min-reviews/data.json = %+v, want %+v", got, want)
+	}
+	if _, ok := files["other/data.json"]; !ok {
+		t.Errorf("GenerateData() wrote %v, want other/data.json", files)
+	}
+
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := composer.Bundle(context.Background(), pl, Config{Bundle: bundlePath}); err != nil {
+		t.Fatalf("Bundle() error = %v", err)
+	}
+	b, err := LoadBundle(bundlePath, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.Data["min-reviews"]; ok {
+		t.Errorf("bundle data = %v, want check data only under %s", b.Data, checksNamespace)
+	}
+
+	tests := []struct {
+		name    string
+		options []func(*rego.Rego)
+	}{
+		{
+			// Conftest loads the policy tree as both policies and data.
+			name: "policy tree",
+			options: []func(*rego.Rego){
+				rego.Query("data.main.deny"),
+				rego.Load([]string{filepath.Join(output, "policy")}, nil),
+			},
+		},
+		{
+			name: "bundle",
+			options: []func(*rego.Rego){
+				rego.Query("data.checks[\"min-reviews\"].main.deny"),
+				rego.ParsedBundle("policy", b),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for reviews, want := range map[int][]any{1: {"2 reviews required"}, 2: {}} {
+				options := append([]func(*rego.Rego){rego.Input(map[string]any{"reviews": reviews})}, tt.options...)
+				rs, err := rego.New(options...).Eval(context.Background())
+				if err != nil {
+					t.Fatalf("Eval() error = %v", err)
+				}
+				if len(rs) != 1 || !reflect.DeepEqual(rs[0].Expressions[0].Value, want) {
+					t.Errorf("deny with %d reviews = %v, want %v", reviews, rs, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateDataConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "check ID is a package",
+			files:   map[string]string{"policy/main/policy.rego": "package main\n"},
+			wantErr: "data for check main conflicts with package main of check main",
+		},
+		{
+			name: "check ID is another check's package",
+			files: map[string]string{
+				"policy/main/policy.rego":  "package checks.main\n",
+				"policy/other/policy.rego": "package main.other\n",
+			},
+			wantErr: "data for check main conflicts with package main.other of check other",
+		},
+		{
+			name:    "package is a data field",
+			files:   map[string]string{"policy/main/policy.rego": "package parameters\n"},
+			wantErr: "package parameters of check main conflicts with its data document",
+		},
+		{
+			name:    "no package",
+			files:   map[string]string{"policy/main/README.md": "no policy\n"},
+			wantErr: "no Rego package found for check main",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := t.TempDir()
+			writeFiles(t, output, tt.files)
+			var checkIDs []string
+			for name := range tt.files {
+				checkIDs = append(checkIDs, strings.Split(name, "/")[1])
+			}
+			err := NewComposer("", output).GenerateData(testPolicy(checkIDs...))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GenerateData() error = %v, want %q", err, tt.wantErr)
+			}
+			if files := readFiles(t, output); len(files) != len(tt.files) {
+				t.Errorf("GenerateData() wrote data despite the error: %v", files)
+			}
+		})
+	}
+}
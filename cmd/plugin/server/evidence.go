@@ -0,0 +1,400 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+// TimeWindow bounds an evidence query. A zero Start or End leaves that side
+// of the window to the evidence source's default.
+type TimeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// EvidenceRecord is a single piece of evidence for a check, normalized across evidence sources.
+type EvidenceRecord struct {
+	// CheckID is the check the evidence was collected for.
+	CheckID string
+	// Timestamp is when the evidence was recorded.
+	Timestamp time.Time
+	// RunID identifies the evaluation run that recorded the evidence, such as a trace ID.
+	// Records without a run ID are treated as a single run.
+	RunID string
+	// SubjectID identifies the resource the evidence is about.
+	SubjectID string
+	// SubjectTitle is a human-readable name for the resource.
+	SubjectTitle string
+	// Attributes are the source-specific values that identify the resource, such as Loki
+	// labels, used to map evidence to assessment plan subjects.
+	Attributes map[string]string
+	// Result is the outcome recorded for the resource.
+	Result policy.Result
+	// Reason explains the result.
+	Reason string
+	// Props holds source-specific details about the evidence.
+	Props []policy.Property
+	// Summary marks a record that summarizes the evaluation of the subject in a run, such as
+	// a completed scan, rather than a single finding. It gives the result of runs without
+	// findings.
+	Summary bool
+	// Links point to the evidence in the source system.
+	Links []policy.Link
+	// Raw is the evidence as fetched from the source, such as a Loki log entry, and is
+	// written to evidence snapshots.
+	Raw []byte `json:"-"`
+}
+
+// EvidenceSource retrieves evidence for checks from a backend.
+type EvidenceSource interface {
+	// Name identifies the evidence source in observation properties.
+	Name() string
+	// Query returns the evidence recorded for the check ID within the window.
+	Query(ctx context.Context, checkID string, window TimeWindow) ([]EvidenceRecord, error)
+}
+
+// missingEvidenceReasoner is implemented by evidence sources that are not queried by time,
+// so a check without evidence is explained by where the source looked rather than by the
+// query window.
+type missingEvidenceReasoner interface {
+	// missingEvidenceReason explains why no evidence was found for the check.
+	missingEvidenceReason(checkID string) string
+}
+
+// defaultEvidenceConcurrency is the number of checks queried at the same time by default.
+const defaultEvidenceConcurrency = 4
+
+// runAware is implemented by evidence sources that keep state for a single GetResults run,
+// such as a query budget.
+type runAware interface {
+	// startRun is called before the checks of a run are queried.
+	startRun()
+}
+
+// healthChecker is implemented by evidence sources that can verify their backend is
+// reachable and accepts the configured credentials.
+type healthChecker interface {
+	checkHealth(ctx context.Context) error
+}
+
+// queryDescriber is implemented by evidence sources that can describe the queries they
+// would run for a check without running them.
+type queryDescriber interface {
+	describeQuery(checkID string, window TimeWindow) ([]any, error)
+}
+
+// NewEvidenceSource creates the EvidenceSource selected by the evidence-source option.
+// Loki is used when no evidence source is configured.
+func NewEvidenceSource(config Config) (EvidenceSource, error) {
+	switch config.EvidenceSource {
+	case "", evidenceSourceLoki:
+		return newLokiSource(config)
+	case evidenceSourceLocal:
+		return &localSource{config: config}, nil
+	case evidenceSourceConftest:
+		return &conftestSource{config: config}, nil
+	default:
+		return nil, fmt.Errorf("unsupported evidence-source %q: must be %s, %s, or %s", config.EvidenceSource, evidenceSourceLoki, evidenceSourceLocal, evidenceSourceConftest)
+	}
+}
+
+// newObservation converts the evidence records for a check into an observation with a
+// subject per record. evidenceCount is the number of records collected before grouping.
+func newObservation(source, checkID string, records []EvidenceRecord, evidenceCount int) policy.ObservationByCheck {
+	observation := policy.ObservationByCheck{
+		Title:             fmt.Sprintf("Policy Check for %s", checkID),
+		Description:       fmt.Sprintf("Evidence collected from %s for policy %s", source, checkID),
+		CheckID:           checkID,
+		Methods:           []string{"TEST"},
+		Subjects:          []policy.Subject{},
+		Collected:         time.Now(),
+		RelevantEvidences: []policy.Link{},
+		Props: []policy.Property{
+			{Name: "source", Value: source},
+			{Name: "evidence_count", Value: fmt.Sprintf("%d", evidenceCount)},
+			{Name: "policy_id", Value: checkID},
+		},
+	}
+	for _, record := range records {
+		evaluatedOn := record.Timestamp
+		if evaluatedOn.IsZero() {
+			evaluatedOn = observation.Collected
+		}
+		observation.Subjects = append(observation.Subjects, policy.Subject{
+			Title:       record.SubjectTitle,
+			Type:        subjectTypeResource,
+			ResourceID:  record.SubjectID,
+			Result:      record.Result,
+			EvaluatedOn: evaluatedOn,
+			Reason:      record.Reason,
+			Props:       record.Props,
+		})
+		observation.RelevantEvidences = append(observation.RelevantEvidences, record.Links...)
+	}
+	return observation
+}
+
+// evaluationRun is an evaluation run seen in the collected evidence.
+type evaluationRun struct {
+	ID   string
+	Time time.Time
+}
+
+// latestRun returns the most recent evaluation run in records. It returns false if
+// records is empty.
+func latestRun(records []EvidenceRecord) (evaluationRun, bool) {
+	var run evaluationRun
+	var found bool
+	for _, record := range records {
+		if !found || record.Timestamp.After(run.Time) {
+			run = evaluationRun{ID: record.RunID, Time: record.Timestamp}
+			found = true
+		}
+	}
+	return run, found
+}
+
+// resultSeverity orders results from least to most severe when aggregating findings.
+var resultSeverity = map[policy.Result]int{
+	policy.ResultInvalid: 0,
+	policy.ResultPass:    1,
+	policy.ResultWarning: 2,
+	policy.ResultError:   3,
+	policy.ResultFail:    4,
+}
+
+// groupSubjects aggregates the records for each subject into a single record from the
+// subject's latest run, in the order the subjects first appear. The result is the most
+// severe result in that run and the reason lists each distinct finding. A subject whose
+// latest run is not the latest run of the check had nothing recorded in that run, so
+// its earlier findings are reported as resolved.
+func groupSubjects(records []EvidenceRecord, latest evaluationRun) []EvidenceRecord {
+	var order []string
+	bySubject := make(map[string][]EvidenceRecord)
+	for _, record := range records {
+		if _, ok := bySubject[record.SubjectID]; !ok {
+			order = append(order, record.SubjectID)
+		}
+		bySubject[record.SubjectID] = append(bySubject[record.SubjectID], record)
+	}
+
+	grouped := make([]EvidenceRecord, 0, len(order))
+	for _, subjectID := range order {
+		subjectRecords := bySubject[subjectID]
+		run, _ := latestRun(subjectRecords)
+		if run.ID != latest.ID {
+			grouped = append(grouped, EvidenceRecord{
+				CheckID:      subjectRecords[0].CheckID,
+				Timestamp:    latest.Time,
+				RunID:        latest.ID,
+				SubjectID:    subjectID,
+				SubjectTitle: subjectRecords[0].SubjectTitle,
+				Result:       policy.ResultPass,
+				Reason:       "No failures recorded in the latest evaluation run",
+				Props: []policy.Property{
+					{Name: "run_id", Value: latest.ID},
+					{Name: "previous_run_id", Value: run.ID},
+				},
+			})
+			continue
+		}
+		grouped = append(grouped, aggregateRecords(subjectRecords, run))
+	}
+	return grouped
+}
+
+// finding is a distinct finding for a subject, deduplicated across evaluation runs.
+type finding struct {
+	hash string
+	// current is the latest occurrence in the run being reported, if any.
+	current     *EvidenceRecord
+	firstSeen   time.Time
+	lastSeen    time.Time
+	occurrences int
+}
+
+// findingHash identifies a finding by its check, subject, and a SHA-256 hash of its message.
+func findingHash(record EvidenceRecord) string {
+	sum := sha256.Sum256([]byte(record.Reason))
+	return hex.EncodeToString(sum[:])
+}
+
+// deduplicateFindings combines records that repeat the same finding, in the order the
+// findings first appear. Occurrences and first and last seen times count every run, while
+// the current occurrence is taken from the run with runID.
+func deduplicateFindings(records []EvidenceRecord, runID string) []*finding {
+	type findingKey struct{ checkID, subjectID, hash string }
+	var findings []*finding
+	byKey := make(map[findingKey]*finding)
+	for i, record := range records {
+		key := findingKey{record.CheckID, record.SubjectID, findingHash(record)}
+		f, ok := byKey[key]
+		if !ok {
+			f = &finding{hash: key.hash, firstSeen: record.Timestamp, lastSeen: record.Timestamp}
+			byKey[key] = f
+			findings = append(findings, f)
+		}
+		f.occurrences++
+		if record.Timestamp.Before(f.firstSeen) {
+			f.firstSeen = record.Timestamp
+		}
+		if record.Timestamp.After(f.lastSeen) {
+			f.lastSeen = record.Timestamp
+		}
+		if record.RunID == runID && (f.current == nil || !record.Timestamp.Before(f.current.Timestamp)) {
+			f.current = &records[i]
+		}
+	}
+	return findings
+}
+
+// aggregateRecords combines the records of a subject into the findings recorded in one run.
+// Repeated findings are reported once, with the props and links of their latest occurrence
+// and when and how often they were seen across runs. If the run recorded no findings for the
+// subject, the result and reason come from the run's summary record.
+func aggregateRecords(records []EvidenceRecord, run evaluationRun) EvidenceRecord {
+	var summary *EvidenceRecord
+	findingRecords := make([]EvidenceRecord, 0, len(records))
+	for i, record := range records {
+		if !record.Summary {
+			findingRecords = append(findingRecords, record)
+			continue
+		}
+		if record.RunID == run.ID && (summary == nil || !record.Timestamp.Before(summary.Timestamp)) {
+			summary = &records[i]
+		}
+	}
+
+	aggregate := EvidenceRecord{
+		CheckID:      records[0].CheckID,
+		Timestamp:    run.Time,
+		RunID:        run.ID,
+		SubjectID:    records[0].SubjectID,
+		SubjectTitle: records[0].SubjectTitle,
+	}
+	var reasons []string
+	var findings, occurrences int
+	var firstSeen, lastSeen time.Time
+	seenProps := make(map[policy.Property]struct{})
+	addProp := func(prop policy.Property) {
+		if _, ok := seenProps[prop]; !ok {
+			seenProps[prop] = struct{}{}
+			aggregate.Props = append(aggregate.Props, prop)
+		}
+	}
+	for _, f := range deduplicateFindings(findingRecords, run.ID) {
+		if f.current == nil {
+			continue
+		}
+		record := f.current
+		findings++
+		occurrences += f.occurrences
+		if firstSeen.IsZero() || f.firstSeen.Before(firstSeen) {
+			firstSeen = f.firstSeen
+		}
+		if f.lastSeen.After(lastSeen) {
+			lastSeen = f.lastSeen
+		}
+		if resultSeverity[record.Result] > resultSeverity[aggregate.Result] {
+			aggregate.Result = record.Result
+		}
+		if record.Reason != "" {
+			reasons = append(reasons, record.Reason)
+		}
+		for _, prop := range record.Props {
+			addProp(prop)
+		}
+		addProp(policy.Property{Name: "finding_hash", Value: f.hash})
+		aggregate.Links = append(aggregate.Links, record.Links...)
+	}
+	aggregate.Reason = strings.Join(reasons, "; ")
+	if findings == 0 && summary != nil {
+		aggregate.Result = summary.Result
+		aggregate.Reason = summary.Reason
+		aggregate.Props = append(aggregate.Props, summary.Props...)
+		aggregate.Links = append(aggregate.Links, summary.Links...)
+		if run.ID != "" {
+			aggregate.Props = append(aggregate.Props, policy.Property{Name: "run_id", Value: run.ID})
+		}
+		return aggregate
+	}
+	if findings > 1 {
+		aggregate.Props = append(aggregate.Props, policy.Property{Name: "finding_count", Value: fmt.Sprintf("%d", findings)})
+	}
+	aggregate.Props = append(aggregate.Props, policy.Property{Name: "occurrence_count", Value: fmt.Sprintf("%d", occurrences)})
+	if !firstSeen.IsZero() {
+		aggregate.Props = append(aggregate.Props,
+			policy.Property{Name: "first_seen", Value: firstSeen.UTC().Format(time.RFC3339Nano)},
+			policy.Property{Name: "last_seen", Value: lastSeen.UTC().Format(time.RFC3339Nano)},
+		)
+	}
+	if run.ID != "" {
+		aggregate.Props = append(aggregate.Props, policy.Property{Name: "run_id", Value: run.ID})
+	}
+	return aggregate
+}
+
+// runObservation creates the observation for a check with a subject per resource, counting
+// only each resource's latest run. run is the latest run of the check's own records.
+func runObservation(source, checkID string, run evaluationRun, records []EvidenceRecord) policy.ObservationByCheck {
+	observation := newObservation(source, checkID, groupSubjects(records, run), len(records))
+	if run.ID != "" {
+		observation.Props = append(observation.Props, policy.Property{Name: "run_id", Value: run.ID})
+	}
+	return observation
+}
+
+// noEvidenceObservation creates the observation for a check when no evidence was collected
+// within the window, or by a source that is not queried by time. The check is reported with an error result so it is not satisfied
+// rather than silently missing from the assessment results.
+func noEvidenceObservation(source EvidenceSource, checkID string, window TimeWindow) policy.ObservationByCheck {
+	reason := fmt.Sprintf("No evidence collected from %s %s", source.Name(), window)
+	if reasoner, ok := source.(missingEvidenceReasoner); ok {
+		reason = reasoner.missingEvidenceReason(checkID)
+	}
+	observation := newObservation(source.Name(), checkID, nil, 0)
+	observation.Subjects = append(observation.Subjects, policy.Subject{
+		Title:       "No evidence",
+		Type:        subjectTypeResource,
+		ResourceID:  checkID,
+		Result:      policy.ResultError,
+		EvaluatedOn: observation.Collected,
+		Reason:      reason,
+	})
+	return observation
+}
+
+// erroredObservation creates the observation for a check whose evidence could not be
+// fetched, so the check is reported with an error result instead of being omitted.
+func erroredObservation(source, checkID string, err error) policy.ObservationByCheck {
+	observation := newObservation(source, checkID, nil, 0)
+	observation.Subjects = append(observation.Subjects, policy.Subject{
+		Title:       "Evidence query failed",
+		Type:        subjectTypeResource,
+		ResourceID:  checkID,
+		Result:      policy.ResultError,
+		EvaluatedOn: observation.Collected,
+		Reason:      fmt.Sprintf("Failed to query evidence from %s: %v", source, err),
+	})
+	return observation
+}
+
+// String describes the window for observation reasons.
+func (w TimeWindow) String() string {
+	switch {
+	case w.Start.IsZero() && w.End.IsZero():
+		return "within the default query window"
+	case w.Start.IsZero():
+		return fmt.Sprintf("before %s", w.End.Format(time.RFC3339))
+	case w.End.IsZero():
+		return fmt.Sprintf("since %s", w.Start.Format(time.RFC3339))
+	default:
+		return fmt.Sprintf("between %s and %s", w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+// runRecord returns a record for subject in run, recorded minutes after the start of the day.
+func runRecord(run, subject string, minutes int, result policy.Result, reason string) EvidenceRecord {
+	return EvidenceRecord{
+		CheckID:   "check-1",
+		Timestamp: time.Date(2026, 1, 2, 0, minutes, 0, 0, time.UTC),
+		RunID:     run,
+		SubjectID: subject,
+		Result:    result,
+		Reason:    reason,
+	}
+}
+
+func TestGroupSubjects(t *testing.T) {
+	summary := runRecord("run-2", "repo-c", 11, policy.ResultPass, "Scan completed")
+	summary.Summary = true
+	records := []EvidenceRecord{
+		runRecord("run-1", "repo-a", 1, policy.ResultFail, "unprotected"),
+		runRecord("run-1", "repo-b", 1, policy.ResultFail, "no reviews"),
+		runRecord("run-2", "repo-a", 10, policy.ResultWarning, "stale reviews"),
+		runRecord("run-2", "repo-a", 10, policy.ResultFail, "unprotected"),
+		summary,
+	}
+	latest, ok := latestRun(records)
+	if !ok || latest.ID != "run-2" {
+		t.Fatalf("latestRun() = %+v, %v, want run-2", latest, ok)
+	}
+
+	type want struct {
+		subject string
+		result  policy.Result
+		reason  string
+	}
+	wants := []want{
+		{subject: "repo-a", result: policy.ResultFail, reason: "unprotected; stale reviews"},
+		{subject: "repo-b", result: policy.ResultPass, reason: "No failures recorded in the latest evaluation run"},
+		{subject: "repo-c", result: policy.ResultPass, reason: "Scan completed"},
+	}
+	grouped := groupSubjects(records, latest)
+	if len(grouped) != len(wants) {
+		t.Fatalf("groupSubjects() returned %d records, want %d: %+v", len(grouped), len(wants), grouped)
+	}
+	for i, want := range wants {
+		got := grouped[i]
+		if got.SubjectID != want.subject || got.Result != want.result || got.Reason != want.reason {
+			t.Errorf("record %d = %s %s (%s), want %s %s (%s)", i, got.SubjectID, got.Result, got.Reason, want.subject, want.result, want.reason)
+		}
+		if got.RunID != "run-2" || propValue(got.Props, "run_id") != "run-2" {
+			t.Errorf("record %d run = %s (props %v), want run-2", i, got.RunID, got.Props)
+		}
+	}
+	if got := propValue(grouped[1].Props, "previous_run_id"); got != "run-1" {
+		t.Errorf("resolved record previous_run_id = %q, want run-1", got)
+	}
+}
+
+func TestAggregateRecords(t *testing.T) {
+	run := evaluationRun{ID: "run-2", Time: time.Date(2026, 1, 2, 0, 10, 0, 0, time.UTC)}
+	summary := func(run string, minutes int, result policy.Result, reason string) EvidenceRecord {
+		record := runRecord(run, "repo-a", minutes, result, reason)
+		record.Summary = true
+		return record
+	}
+
+	tests := []struct {
+		name       string
+		records    []EvidenceRecord
+		wantResult policy.Result
+		wantReason string
+		wantProps  map[string]string
+	}{
+		{
+			name: "most severe finding",
+			records: []EvidenceRecord{
+				runRecord("run-2", "repo-a", 10, policy.ResultWarning, "stale reviews"),
+				runRecord("run-2", "repo-a", 10, policy.ResultFail, "unprotected"),
+				runRecord("run-2", "repo-a", 10, policy.ResultPass, ""),
+			},
+			wantResult: policy.ResultFail,
+			wantReason: "stale reviews; unprotected",
+			wantProps:  map[string]string{"finding_count": "3", "occurrence_count": "3", "run_id": "run-2"},
+		},
+		{
+			name: "findings of other runs",
+			records: []EvidenceRecord{
+				runRecord("run-1", "repo-a", 1, policy.ResultFail, "unprotected"),
+				runRecord("run-2", "repo-a", 10, policy.ResultWarning, "stale reviews"),
+			},
+			wantResult: policy.ResultWarning,
+			wantReason: "stale reviews",
+			wantProps:  map[string]string{"finding_count": "", "occurrence_count": "1", "run_id": "run-2"},
+		},
+		{
+			name: "summary without findings",
+			records: []EvidenceRecord{
+				runRecord("run-1", "repo-a", 1, policy.ResultFail, "unprotected"),
+				summary("run-1", 1, policy.ResultFail, "Scan found 1 issue"),
+				summary("run-2", 9, policy.ResultError, "Scan started"),
+				summary("run-2", 10, policy.ResultPass, "Scan completed"),
+			},
+			wantResult: policy.ResultPass,
+			wantReason: "Scan completed",
+			wantProps:  map[string]string{"occurrence_count": "", "run_id": "run-2"},
+		},
+		{
+			name: "findings take precedence over the summary",
+			records: []EvidenceRecord{
+				summary("run-2", 10, policy.ResultPass, "Scan completed"),
+				runRecord("run-2", "repo-a", 10, policy.ResultFail, "unprotected"),
+			},
+			wantResult: policy.ResultFail,
+			wantReason: "unprotected",
+			wantProps:  map[string]string{"occurrence_count": "1", "run_id": "run-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := aggregateRecords(tt.records, run)
+			if got.Result != tt.wantResult || got.Reason != tt.wantReason {
+				t.Errorf("aggregateRecords() = %s (%s), want %s (%s)", got.Result, got.Reason, tt.wantResult, tt.wantReason)
+			}
+			if got.SubjectID != "repo-a" || got.RunID != run.ID || !got.Timestamp.Equal(run.Time) {
+				t.Errorf("aggregateRecords() = %s in %s at %v, want repo-a in %s at %v", got.SubjectID, got.RunID, got.Timestamp, run.ID, run.Time)
+			}
+			props := make(map[string]string)
+			for name := range tt.wantProps {
+				props[name] = propValue(got.Props, name)
+			}
+			if !reflect.DeepEqual(props, tt.wantProps) {
+				t.Errorf("aggregateRecords() props = %v, want %v", props, tt.wantProps)
+			}
+		})
+	}
+}
+
+func TestDeduplicateFindings(t *testing.T) {
+	otherCheck := runRecord("run-2", "repo-a", 10, policy.ResultFail, "unprotected")
+	otherCheck.CheckID = "check-2"
+
+	type want struct {
+		reason string
+		// current is the index of the current occurrence in records, or -1 if there is none.
+		current     int
+		firstSeen   int
+		lastSeen    int
+		occurrences int
+	}
+	tests := []struct {
+		name    string
+		records []EvidenceRecord
+		want    []want
+	}{
+		{
+			name: "same finding across runs",
+			records: []EvidenceRecord{
+				runRecord("run-2", "repo-a", 10, policy.ResultFail, "unprotected"),
+				runRecord("run-1", "repo-a", 1, policy.ResultFail, "unprotected"),
+				runRecord("run-2", "repo-a", 12, policy.ResultFail, "unprotected"),
+			},
+			want: []want{{reason: "unprotected", current: 2, firstSeen: 1, lastSeen: 12, occurrences: 3}},
+		},
+		{
+			name: "different messages",
+			records: []EvidenceRecord{
+				runRecord("run-2", "repo-a", 10, policy.ResultFail, "unprotected"),
+				runRecord("run-2", "repo-a", 10, policy.ResultFail, "Unprotected"),
+			},
+			want: []want{
+				{reason: "unprotected", current: 0, firstSeen: 10, lastSeen: 10, occurrences: 1},
+				{reason: "Unprotected", current: 1, firstSeen: 10, lastSeen: 10, occurrences: 1},
+			},
+		},
+		{
+			name: "different subjects and checks",
+			records: []EvidenceRecord{
+				runRecord("run-2", "repo-a", 10, policy.ResultFail, "unprotected"),
+				runRecord("run-2", "repo-b", 10, policy.ResultFail, "unprotected"),
+				otherCheck,
+			},
+			want: []want{
+				{reason: "unprotected", current: 0, firstSeen: 10, lastSeen: 10, occurrences: 1},
+				{reason: "unprotected", current: 1, firstSeen: 10, lastSeen: 10, occurrences: 1},
+				{reason: "unprotected", current: 2, firstSeen: 10, lastSeen: 10, occurrences: 1},
+			},
+		},
+		{
+			name: "resolved before the run",
+			records: []EvidenceRecord{
+				runRecord("run-1", "repo-a", 1, policy.ResultFail, "unprotected"),
+			},
+			want: []want{{reason: "unprotected", current: -1, firstSeen: 1, lastSeen: 1, occurrences: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := deduplicateFindings(tt.records, "run-2")
+			if len(findings) != len(tt.want) {
+				t.Fatalf("deduplicateFindings() returned %d findings, want %d", len(findings), len(tt.want))
+			}
+			for i, want := range tt.want {
+				f := findings[i]
+				if f.hash != findingHash(EvidenceRecord{Reason: want.reason}) {
+					t.Errorf("finding %d hash = %s, want the hash of %q", i, f.hash, want.reason)
+				}
+				if f.occurrences != want.occurrences || f.firstSeen.Minute() != want.firstSeen || f.lastSeen.Minute() != want.lastSeen {
+					t.Errorf("finding %d seen %d times between minutes %d and %d, want %d times between %d and %d",
+						i, f.occurrences, f.firstSeen.Minute(), f.lastSeen.Minute(), want.occurrences, want.firstSeen, want.lastSeen)
+				}
+				switch {
+				case want.current < 0 && f.current != nil:
+					t.Errorf("finding %d current = %+v, want none", i, *f.current)
+				case want.current >= 0 && f.current != &tt.records[want.current]:
+					t.Errorf("finding %d current = %+v, want record %d", i, f.current, want.current)
+				}
+			}
+		})
+	}
+}
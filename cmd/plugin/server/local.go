@@ -0,0 +1,354 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/bundle"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+const (
+	evidenceSourceLoki  = "loki"
+	evidenceSourceLocal = "local"
+)
+
+// Conftest rule names evaluated for each check. Deny and violation results fail a
+// subject and warn results downgrade it to a warning.
+var (
+	failureRules = []string{"deny", "violation"}
+	warningRules = []string{"warn"}
+)
+
+// inputDocument is a raw input document read from the policy results directory.
+type inputDocument struct {
+	Path  string
+	Value any
+}
+
+// localEvaluator evaluates the generated policies in-process against input documents.
+type localEvaluator struct {
+	bundle *bundle.Bundle
+	// packages maps each check ID to the Rego packages of its policies.
+	packages map[string][]string
+	// inputKeys maps each check ID to the top-level input fields its policies read. A check
+	// without an entry reads the input as a whole and applies to every input.
+	inputKeys map[string][]string
+}
+
+// newLocalEvaluator loads the configured bundle, verifying it when a verification key is set,
+// or the policy output directory if no bundle is configured.
+func newLocalEvaluator(config Config) (*localEvaluator, error) {
+	var b *bundle.Bundle
+	var err error
+	if config.Bundle != "" {
+		b, err = LoadBundle(config.Bundle, config)
+	} else {
+		b, err = NewComposer(config.PolicyTemplates, config.PolicyOutput).policyBundle([]string{""})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make(map[string][]string)
+	inputKeys := make(map[string][]string)
+	wholeInput := make(map[string]bool)
+	for _, module := range b.Modules {
+		checkID, ok := moduleCheckID(module.Path)
+		if !ok || module.Parsed == nil || isTestFile(module.Path) {
+			continue
+		}
+		pkg := strings.TrimPrefix(module.Parsed.Package.Path.String(), "data.")
+		if !slices.Contains(packages[checkID], pkg) {
+			packages[checkID] = append(packages[checkID], pkg)
+		}
+		keys, ok := moduleInputKeys(module.Parsed)
+		if !ok {
+			wholeInput[checkID] = true
+		}
+		for _, key := range keys {
+			if !slices.Contains(inputKeys[checkID], key) {
+				inputKeys[checkID] = append(inputKeys[checkID], key)
+			}
+		}
+	}
+	for checkID := range wholeInput {
+		delete(inputKeys, checkID)
+	}
+	return &localEvaluator{bundle: b, packages: packages, inputKeys: inputKeys}, nil
+}
+
+// moduleInputKeys returns the top-level input fields referenced by a module, such as
+// values for input.values[_]. It returns false if the module references the input as a
+// whole or through a computed field.
+func moduleInputKeys(module *ast.Module) ([]string, bool) {
+	var keys []string
+	whole := false
+	ast.WalkRefs(module, func(ref ast.Ref) bool {
+		if !ref.HasPrefix(ast.InputRootRef) {
+			return false
+		}
+		if len(ref) < 2 {
+			whole = true
+			return false
+		}
+		key, ok := ref[1].Value.(ast.String)
+		if !ok {
+			whole = true
+			return false
+		}
+		if !slices.Contains(keys, string(key)) {
+			keys = append(keys, string(key))
+		}
+		return false
+	})
+	return keys, !whole
+}
+
+// applies reports whether an input has any of the top-level fields the check's policies
+// read. Like Conftest namespaces, this keeps a check from being evaluated against inputs
+// for other checks, where it would find nothing and pass.
+func (e *localEvaluator) applies(checkID string, input inputDocument) bool {
+	keys, ok := e.inputKeys[checkID]
+	if !ok {
+		return true
+	}
+	document, ok := input.Value.(map[string]any)
+	if !ok {
+		return false
+	}
+	for _, key := range keys {
+		if _, ok := document[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleCheckID returns the check ID for a module written by GeneratePolicySet
+// at policy/<check ID>/... relative to the bundle root.
+func moduleCheckID(modulePath string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(path.Clean(filepath.ToSlash(modulePath)), "/"), "/")
+	if len(parts) < 3 || parts[0] != "policy" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// localSource is an EvidenceSource that evaluates the generated policies in-process
+// against each input document in the policy results directory. The policies and inputs
+// are loaded on the first query.
+type localSource struct {
+	config    Config
+	once      sync.Once
+	evaluator *localEvaluator
+	inputs    []inputDocument
+	err       error
+	// mu serializes evaluations, which compile the shared bundle.
+	mu sync.Mutex
+}
+
+func (l *localSource) Name() string {
+	return evidenceSourceLocal
+}
+
+// Query evaluates the check against every input document it applies to and returns a record
+// per input. The window is ignored because inputs are evaluated when queried.
+func (l *localSource) Query(ctx context.Context, checkID string, _ TimeWindow) ([]EvidenceRecord, error) {
+	l.once.Do(l.load)
+	if l.err != nil {
+		return nil, l.err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.evaluator.evaluateCheck(ctx, checkID, l.inputs)
+}
+
+func (l *localSource) missingEvidenceReason(checkID string) string {
+	return fmt.Sprintf("No input documents in %s apply to the policies of %s", l.config.PolicyResults, checkID)
+}
+
+func (l *localSource) load() {
+	l.inputs, l.err = loadInputs(l.config.PolicyResults)
+	if l.err != nil {
+		l.err = fmt.Errorf("failed to read inputs from %s: %w", l.config.PolicyResults, l.err)
+		return
+	}
+	if len(l.inputs) == 0 {
+		logger.Warn("No input documents found for local evaluation", "path", l.config.PolicyResults)
+	}
+	l.evaluator, l.err = newLocalEvaluator(l.config)
+	if l.err != nil {
+		l.err = fmt.Errorf("failed to load policies for local evaluation: %w", l.err)
+	}
+}
+
+func (e *localEvaluator) evaluateCheck(ctx context.Context, checkID string, inputs []inputDocument) ([]EvidenceRecord, error) {
+	packages, ok := e.packages[checkID]
+	if !ok {
+		return nil, fmt.Errorf("no policy found for check %s", checkID)
+	}
+
+	queries := make(map[string]rego.PreparedEvalQuery, len(packages))
+	for _, pkg := range packages {
+		query, err := rego.New(
+			rego.Query("data."+pkg),
+			rego.ParsedBundle(checkID, e.bundle),
+		).PrepareForEval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		queries[pkg] = query
+	}
+
+	records := make([]EvidenceRecord, 0, len(inputs))
+	for _, input := range inputs {
+		if !e.applies(checkID, input) {
+			logger.Debug("Skipping input the check does not apply to", "policy_id", checkID, "input", input.Path)
+			continue
+		}
+		var failures, warnings []string
+		for _, pkg := range packages {
+			query := queries[pkg]
+			rs, err := query.Eval(ctx, rego.EvalInput(input.Value))
+			if err != nil {
+				return nil, fmt.Errorf("evaluating %s against %s: %w", pkg, input.Path, err)
+			}
+			if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+				continue
+			}
+			document, ok := rs[0].Expressions[0].Value.(map[string]any)
+			if !ok {
+				continue
+			}
+			failures = append(failures, ruleMessages(document, failureRules, checkID)...)
+			warnings = append(warnings, ruleMessages(document, warningRules, checkID)...)
+		}
+
+		record := EvidenceRecord{
+			CheckID:      checkID,
+			Timestamp:    time.Now(),
+			SubjectID:    inputResourceID(input),
+			SubjectTitle: inputTitle(input),
+			Attributes:   map[string]string{"input": input.Path},
+			Props: []policy.Property{
+				{Name: "input", Value: input.Path},
+			},
+		}
+		switch {
+		case len(failures) > 0:
+			record.Result = policy.ResultFail
+			record.Reason = strings.Join(append(failures, warnings...), "; ")
+		case len(warnings) > 0:
+			record.Result = policy.ResultWarning
+			record.Reason = strings.Join(warnings, "; ")
+		default:
+			record.Result = policy.ResultPass
+			record.Reason = "No violations found"
+		}
+		if raw, err := json.Marshal(input.Value); err == nil {
+			record.Raw = raw
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// ruleMessages collects the messages produced by the named rules in a package document.
+// Results may be strings or objects with a msg field. Object results with a short_name
+// that does not match the check ID belong to another check and are skipped.
+func ruleMessages(document map[string]any, rules []string, checkID string) []string {
+	var messages []string
+	for _, name := range rules {
+		values, ok := document[name].([]any)
+		if !ok {
+			continue
+		}
+		for _, value := range values {
+			switch v := value.(type) {
+			case string:
+				messages = append(messages, v)
+			case map[string]any:
+				if shortName, ok := v["short_name"].(string); ok && shortName != checkID {
+					continue
+				}
+				if msg, ok := v["msg"].(string); ok {
+					messages = append(messages, msg)
+					continue
+				}
+				data, _ := json.Marshal(v)
+				messages = append(messages, string(data))
+			default:
+				messages = append(messages, fmt.Sprint(v))
+			}
+		}
+	}
+	sort.Strings(messages)
+	return messages
+}
+
+// loadInputs reads every JSON and YAML document under dir.
+func loadInputs(dir string) ([]inputDocument, error) {
+	var inputs []inputDocument
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".json" && ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		content, err := os.ReadFile(filepath.Clean(p))
+		if err != nil {
+			return err
+		}
+		var value any
+		if ext == ".json" {
+			err = json.Unmarshal(content, &value)
+		} else {
+			err = yaml.Unmarshal(content, &value)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse input %s: %w", p, err)
+		}
+		inputs = append(inputs, inputDocument{Path: p, Value: value})
+		return nil
+	})
+	return inputs, err
+}
+
+// inputTitle uses the name of a snappy snapshot when present, otherwise the file name.
+func inputTitle(input inputDocument) string {
+	if doc, ok := input.Value.(map[string]any); ok {
+		if name, ok := doc["name"].(string); ok && name != "" {
+			return name
+		}
+	}
+	return filepath.Base(input.Path)
+}
+
+// inputResourceID uses the id of a snappy snapshot when present, otherwise the file path.
+func inputResourceID(input inputDocument) string {
+	if doc, ok := input.Value.(map[string]any); ok {
+		if id, ok := doc["id"].(string); ok && id != "" {
+			return id
+		}
+	}
+	return input.Path
+}
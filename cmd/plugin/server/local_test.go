@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+func TestModuleInputKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		module    string
+		wantKeys  []string
+		wantWhole bool
+	}{
+		{
+			name:     "top-level fields",
+			module:   `deny contains "x" if { input.values[_] == input.branch.name }`,
+			wantKeys: []string{"values", "branch"},
+		},
+		{
+			name:     "bracket field",
+			module:   `deny contains "x" if input["resource_changes"][_].type == "aws_s3_bucket"`,
+			wantKeys: []string{"resource_changes"},
+		},
+		{
+			name:   "no input",
+			module: `deny contains "x" if false`,
+		},
+		{
+			name:      "whole input",
+			module:    `deny contains "x" if { doc := input; doc.values }`,
+			wantWhole: true,
+		},
+		{
+			name:      "whole input passed to a function",
+			module:    "deny contains \"x\" if count(input) == 0",
+			wantWhole: true,
+		},
+		{
+			name:      "computed field",
+			module:    `deny contains "x" if { some key; input[key] }`,
+			wantWhole: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			module := ast.MustParseModuleWithOpts("package main\n\n"+tt.module, ast.ParserOptions{RegoVersion: ast.RegoV1})
+			keys, ok := moduleInputKeys(module)
+			if ok == tt.wantWhole {
+				t.Fatalf("moduleInputKeys() ok = %v, want %v", ok, !tt.wantWhole)
+			}
+			if !tt.wantWhole && !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("moduleInputKeys() = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestApplies(t *testing.T) {
+	evaluator := &localEvaluator{inputKeys: map[string][]string{"branches": {"values", "branch"}}}
+	tests := []struct {
+		name    string
+		checkID string
+		input   any
+		want    bool
+	}{
+		{name: "has a field", checkID: "branches", input: map[string]any{"branch": "main"}, want: true},
+		{name: "has another field", checkID: "branches", input: map[string]any{"values": []any{}, "other": 1}, want: true},
+		{name: "has none of the fields", checkID: "branches", input: map[string]any{"resource_changes": []any{}}},
+		{name: "not an object", checkID: "branches", input: []any{"branch"}},
+		{name: "check reads the whole input", checkID: "whole", input: []any{"anything"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluator.applies(tt.checkID, inputDocument{Path: "input.json", Value: tt.input}); got != tt.want {
+				t.Errorf("applies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		document map[string]any
+		rules    []string
+		want     []string
+	}{
+		{
+			name:     "string results",
+			document: map[string]any{"deny": []any{"b failed", "a failed"}},
+			rules:    failureRules,
+			want:     []string{"a failed", "b failed"},
+		},
+		{
+			name: "object results",
+			document: map[string]any{"violation": []any{
+				map[string]any{"msg": "with message"},
+				map[string]any{"reason": "without message"},
+			}},
+			rules: failureRules,
+			want:  []string{"with message", `{"reason":"without message"}`},
+		},
+		{
+			name: "short name of this check",
+			document: map[string]any{"deny": []any{
+				map[string]any{"msg": "mine", "short_name": "check-1"},
+				map[string]any{"msg": "theirs", "short_name": "check-2"},
+			}},
+			rules: failureRules,
+			want:  []string{"mine"},
+		},
+		{
+			name: "deny and violation",
+			document: map[string]any{
+				"deny":      []any{"denied"},
+				"violation": []any{map[string]any{"msg": "violated"}},
+				"warn":      []any{"warned"},
+			},
+			rules: failureRules,
+			want:  []string{"denied", "violated"},
+		},
+		{
+			name:     "other values",
+			document: map[string]any{"warn": []any{true}},
+			rules:    warningRules,
+			want:     []string{"true"},
+		},
+		{
+			name:     "rule is not a set",
+			document: map[string]any{"deny": true},
+			rules:    failureRules,
+		},
+		{
+			name:     "no results",
+			document: map[string]any{"allow": true},
+			rules:    warningRules,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ruleMessages(tt.document, tt.rules, "check-1"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ruleMessages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateCheck(t *testing.T) {
+	output := t.TempDir()
+	writeFiles(t, output, map[string]string{
+		"policy/check-1/policy.rego": `package main
+
+import rego.v1
+
+deny contains "unprotected" if not input.branch.protected
+
+warn contains "no reviews" if input.branch.reviews == 0
+`,
+	})
+	evaluator, err := newLocalEvaluator(Config{PolicyOutput: output})
+	if err != nil {
+		t.Fatalf("newLocalEvaluator() error = %v", err)
+	}
+
+	branch := func(protected bool, reviews int) inputDocument {
+		return inputDocument{Path: "input.json", Value: map[string]any{"branch": map[string]any{"protected": protected, "reviews": reviews}}}
+	}
+	tests := []struct {
+		name       string
+		input      inputDocument
+		wantResult policy.Result
+		wantReason string
+	}{
+		{name: "deny and warn", input: branch(false, 0), wantResult: policy.ResultFail, wantReason: "unprotected; no reviews"},
+		{name: "deny", input: branch(false, 2), wantResult: policy.ResultFail, wantReason: "unprotected"},
+		{name: "warn", input: branch(true, 0), wantResult: policy.ResultWarning, wantReason: "no reviews"},
+		{name: "clean", input: branch(true, 2), wantResult: policy.ResultPass, wantReason: "No violations found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := evaluator.evaluateCheck(context.Background(), "check-1", []inputDocument{tt.input})
+			if err != nil {
+				t.Fatalf("evaluateCheck() error = %v", err)
+			}
+			if len(records) != 1 {
+				t.Fatalf("evaluateCheck() returned %d records, want 1", len(records))
+			}
+			if records[0].Result != tt.wantResult || records[0].Reason != tt.wantReason {
+				t.Errorf("evaluateCheck() = %s (%s), want %s (%s)", records[0].Result, records[0].Reason, tt.wantResult, tt.wantReason)
+			}
+		})
+	}
+
+	t.Run("skips inputs for other checks", func(t *testing.T) {
+		other := inputDocument{Path: "plan.json", Value: map[string]any{"resource_changes": []any{}}}
+		records, err := evaluator.evaluateCheck(context.Background(), "check-1", []inputDocument{other, branch(true, 2)})
+		if err != nil {
+			t.Fatalf("evaluateCheck() error = %v", err)
+		}
+		if len(records) != 1 || records[0].Result != policy.ResultPass {
+			t.Errorf("evaluateCheck() = %+v, want one passing record", records)
+		}
+	})
+
+	t.Run("unknown check", func(t *testing.T) {
+		if _, err := evaluator.evaluateCheck(context.Background(), "check-2", []inputDocument{branch(true, 2)}); err == nil {
+			t.Error("evaluateCheck() succeeded, want an error")
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// logqlFilter is a label filter expression such as policy_id="check".
+type logqlFilter struct {
+	Label string
+	Value string
+}
+
+// buildLogQL returns a LogQL query that applies the label filters to the stream selector.
+// Label names must be valid label identifiers and values are quoted with LogQL string
+// escaping, so values taken from assessment plans cannot change the structure of the query.
+func buildLogQL(selector string, filters ...logqlFilter) (string, error) {
+	selector = strings.TrimSpace(selector)
+	if !strings.HasPrefix(selector, "{") || !strings.HasSuffix(selector, "}") {
+		return "", fmt.Errorf("invalid stream selector %q", selector)
+	}
+	var b strings.Builder
+	b.WriteString(selector)
+	for _, filter := range filters {
+		if !lokiLabelNamePattern.MatchString(filter.Label) {
+			return "", fmt.Errorf("invalid label name %q", filter.Label)
+		}
+		if err := validateLogQLValue(filter.Value); err != nil {
+			return "", fmt.Errorf("invalid value for label %s: %w", filter.Label, err)
+		}
+		b.WriteString(" | ")
+		b.WriteString(filter.Label)
+		b.WriteString("=")
+		b.WriteString(quoteLogQL(filter.Value))
+	}
+	return b.String(), nil
+}
+
+// quoteLogQL quotes a string for LogQL. LogQL strings use Go string literal syntax, so
+// quotes, backslashes, and control characters are escaped the same way.
+func quoteLogQL(value string) string {
+	return strconv.Quote(value)
+}
+
+// validateLogQLValue rejects label values that cannot identify a check.
+func validateLogQLValue(value string) error {
+	if value == "" {
+		return fmt.Errorf("value must not be empty")
+	}
+	if !utf8.ValidString(value) {
+		return fmt.Errorf("value %q is not valid UTF-8", value)
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("value %q contains control characters", value)
+		}
+	}
+	return nil
+}
+
+// queryRangeURL returns an encoded Loki query_range URL for the query over [start, end].
+// Loki treats end as exclusive, so it is extended by a nanosecond.
+func queryRangeURL(baseURL, query string, start, end time.Time) string {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(end.Add(time.Nanosecond).UnixNano(), 10))
+	return fmt.Sprintf("%s/loki/api/v1/query_range?%s", strings.TrimSuffix(baseURL, "/"), params.Encode())
+}
+
+// grafanaExploreURL returns a Grafana Explore link that runs the query against the Loki data
+// source over [start, end]. Explore ranges are in milliseconds, so the range is widened to
+// whole milliseconds around the entry.
+func grafanaExploreURL(grafanaURL, datasourceUID, query string, start, end time.Time) (string, error) {
+	type datasource struct {
+		Type string `json:"type"`
+		UID  string `json:"uid"`
+	}
+	type exploreQuery struct {
+		RefID      string     `json:"refId"`
+		Expr       string     `json:"expr"`
+		QueryType  string     `json:"queryType"`
+		Datasource datasource `json:"datasource"`
+	}
+	type exploreRange struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	type pane struct {
+		Datasource string         `json:"datasource"`
+		Queries    []exploreQuery `json:"queries"`
+		Range      exploreRange   `json:"range"`
+	}
+	panes, err := json.Marshal(map[string]pane{
+		"evidence": {
+			Datasource: datasourceUID,
+			Queries: []exploreQuery{{
+				RefID:      "A",
+				Expr:       query,
+				QueryType:  "range",
+				Datasource: datasource{Type: "loki", UID: datasourceUID},
+			}},
+			Range: exploreRange{
+				From: strconv.FormatInt(start.UnixMilli(), 10),
+				To:   strconv.FormatInt(end.UnixMilli()+1, 10),
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Set("schemaVersion", "1")
+	params.Set("panes", string(panes))
+	return fmt.Sprintf("%s/explore?%s", strings.TrimSuffix(grafanaURL, "/"), params.Encode()), nil
+}
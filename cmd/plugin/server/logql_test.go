@@ -0,0 +1,301 @@
+package server
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuoteLogQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "plain", value: "check-1", want: `"check-1"`},
+		{name: "double quote", value: `a"b`, want: `"a\"b"`},
+		{name: "backslash", value: `a\b`, want: `"a\\b"`},
+		{name: "trailing backslash", value: `a\`, want: `"a\\"`},
+		{name: "newline", value: "a\nb", want: `"a\nb"`},
+		{name: "tab", value: "a\tb", want: `"a\tb"`},
+		{name: "backtick", value: "a`b", want: "\"a`b\""},
+		{name: "non-ASCII", value: "prüfung-检查", want: `"prüfung-检查"`},
+		{name: "selector injection", value: `x"} |= "y`, want: `"x\"} |= \"y"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := quoteLogQL(tt.value)
+			if got != tt.want {
+				t.Errorf("quoteLogQL(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+			unquoted, err := strconv.Unquote(got)
+			if err != nil {
+				t.Fatalf("quoteLogQL(%q) = %s does not parse as a string literal: %v", tt.value, got, err)
+			}
+			if unquoted != tt.value {
+				t.Errorf("quoteLogQL(%q) round trips to %q", tt.value, unquoted)
+			}
+		})
+	}
+}
+
+func TestValidateLogQLValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "plain", value: "check-1"},
+		{name: "quotes and backslashes", value: `a"b\c`},
+		{name: "backtick", value: "a`b"},
+		{name: "non-ASCII", value: "prüfung-检查"},
+		{name: "empty", value: "", wantErr: true},
+		{name: "newline", value: "a\nb", wantErr: true},
+		{name: "carriage return", value: "a\rb", wantErr: true},
+		{name: "NUL", value: "a\x00b", wantErr: true},
+		{name: "invalid UTF-8", value: "a\xffb", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateLogQLValue(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("validateLogQLValue(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildLogQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		filters  []logqlFilter
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "no filters",
+			selector: `{service_name="conftest"}`,
+			want:     `{service_name="conftest"}`,
+		},
+		{
+			name:     "trims selector",
+			selector: ` {service_name=~".+"} `,
+			filters:  []logqlFilter{{Label: "policy_id", Value: "check-1"}},
+			want:     `{service_name=~".+"} | policy_id="check-1"`,
+		},
+		{
+			name:     "multiple filters",
+			selector: `{job="c"}`,
+			filters:  []logqlFilter{{Label: "policy_id", Value: "check-1"}, {Label: "trace_id", Value: "abc"}},
+			want:     `{job="c"} | policy_id="check-1" | trace_id="abc"`,
+		},
+		{
+			name:     "escapes quotes and backslashes",
+			selector: `{job="c"}`,
+			filters:  []logqlFilter{{Label: "policy_id", Value: `x"} |= "y\`}},
+			want:     `{job="c"} | policy_id="x\"} |= \"y\\"`,
+		},
+		{
+			name:     "keeps backticks and non-ASCII",
+			selector: `{job="c"}`,
+			filters:  []logqlFilter{{Label: "policy_id", Value: "a`b-检查"}},
+			want:     "{job=\"c\"} | policy_id=\"a`b-检查\"",
+		},
+		{
+			name:     "selector without braces",
+			selector: `job="c"`,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid label name",
+			selector: `{job="c"}`,
+			filters:  []logqlFilter{{Label: "policy.id", Value: "check-1"}},
+			wantErr:  true,
+		},
+		{
+			name:     "label name injection",
+			selector: `{job="c"}`,
+			filters:  []logqlFilter{{Label: `x="y" | policy_id`, Value: "check-1"}},
+			wantErr:  true,
+		},
+		{
+			name:     "newline in value",
+			selector: `{job="c"}`,
+			filters:  []logqlFilter{{Label: "policy_id", Value: "check\n1"}},
+			wantErr:  true,
+		},
+		{
+			name:     "empty value",
+			selector: `{job="c"}`,
+			filters:  []logqlFilter{{Label: "policy_id"}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildLogQL(tt.selector, tt.filters...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildLogQL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("buildLogQL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLokiLabelName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "policy_id", want: "policy_id"},
+		{name: "compliance.status", want: "compliance_status"},
+		{name: "policy-status.detail", want: "policy_status_detail"},
+		{name: " policy.id ", want: "policy_id"},
+		{name: "prüfung", want: "pr_fung"},
+	}
+	for _, tt := range tests {
+		if got := lokiLabelName(tt.name); got != tt.want {
+			t.Errorf("lokiLabelName(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLokiLabelMappingQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		checkID string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			checkID: "check-1",
+			want:    `{service_name=~".+"} | policy_id="check-1"`,
+		},
+		{
+			name:    "attribute name mapped to label",
+			config:  Config{LokiStreamSelector: `{job="c"}`, LokiCheckField: "policy.id"},
+			checkID: `a"b`,
+			want:    `{job="c"} | policy_id="a\"b"`,
+		},
+		{
+			name:    "control characters in check ID",
+			checkID: "check\t1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping, err := newLokiLabelMapping(tt.config)
+			if err != nil {
+				t.Fatalf("newLokiLabelMapping() error = %v", err)
+			}
+			got, err := mapping.query(tt.checkID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("query() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("query() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrafanaExploreURL(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 123456789, time.UTC)
+	end := start.Add(time.Minute)
+
+	queries := []string{
+		`{service_name=~".+"} | policy_id="check-1"`,
+		`{job="c"} | policy_id="x\"} |= \"y\\"`,
+		"{job=\"c\"} | policy_id=\"a`b-检查 & #frag ?q=1 %20\"",
+	}
+	for _, query := range queries {
+		t.Run(query, func(t *testing.T) {
+			link, err := grafanaExploreURL("https://grafana.example.com/", "loki-uid", query, start, end)
+			if err != nil {
+				t.Fatalf("grafanaExploreURL() error = %v", err)
+			}
+			parsed, err := url.Parse(link)
+			if err != nil {
+				t.Fatalf("grafanaExploreURL() = %s does not parse: %v", link, err)
+			}
+			if parsed.Fragment != "" {
+				t.Errorf("grafanaExploreURL() = %s has fragment %q", link, parsed.Fragment)
+			}
+			if got := parsed.Scheme + "://" + parsed.Host + parsed.Path; got != "https://grafana.example.com/explore" {
+				t.Errorf("grafanaExploreURL() base = %s, want https://grafana.example.com/explore", got)
+			}
+			params := parsed.Query()
+			if got := params.Get("schemaVersion"); got != "1" {
+				t.Errorf("schemaVersion = %q, want 1", got)
+			}
+
+			var panes map[string]struct {
+				Datasource string `json:"datasource"`
+				Queries    []struct {
+					RefID      string `json:"refId"`
+					Expr       string `json:"expr"`
+					Datasource struct {
+						Type string `json:"type"`
+						UID  string `json:"uid"`
+					} `json:"datasource"`
+				} `json:"queries"`
+				Range struct {
+					From string `json:"from"`
+					To   string `json:"to"`
+				} `json:"range"`
+			}
+			if err := json.Unmarshal([]byte(params.Get("panes")), &panes); err != nil {
+				t.Fatalf("panes do not decode: %v", err)
+			}
+			pane, ok := panes["evidence"]
+			if !ok || len(pane.Queries) != 1 {
+				t.Fatalf("panes = %+v, want one evidence query", panes)
+			}
+			if got := pane.Queries[0].Expr; got != query {
+				t.Errorf("expr = %s, want %s", got, query)
+			}
+			if pane.Datasource != "loki-uid" || pane.Queries[0].Datasource.UID != "loki-uid" || pane.Queries[0].Datasource.Type != "loki" {
+				t.Errorf("datasource = %+v, want loki data source loki-uid", pane)
+			}
+			if want := strconv.FormatInt(start.UnixMilli(), 10); pane.Range.From != want {
+				t.Errorf("range from = %s, want %s", pane.Range.From, want)
+			}
+			if want := strconv.FormatInt(end.UnixMilli()+1, 10); pane.Range.To != want {
+				t.Errorf("range to = %s, want %s", pane.Range.To, want)
+			}
+		})
+	}
+}
+
+func TestQueryRangeURL(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Minute)
+	query := "{job=\"c\"} | policy_id=\"a`b\\\"检查\""
+
+	link := queryRangeURL("http://loki:3100/", query, start, end)
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("queryRangeURL() = %s does not parse: %v", link, err)
+	}
+	if !strings.HasSuffix(parsed.Path, "/loki/api/v1/query_range") || strings.Contains(parsed.Path, "//") {
+		t.Errorf("queryRangeURL() path = %s", parsed.Path)
+	}
+	params := parsed.Query()
+	if got := params.Get("query"); got != query {
+		t.Errorf("query = %s, want %s", got, query)
+	}
+	if got, want := params.Get("start"), strconv.FormatInt(start.UnixNano(), 10); got != want {
+		t.Errorf("start = %s, want %s", got, want)
+	}
+	if got, want := params.Get("end"), strconv.FormatInt(end.UnixNano()+1, 10); got != want {
+		t.Errorf("end = %s, want %s", got, want)
+	}
+}
@@ -3,11 +3,18 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
 )
 
 // Assisted by: Cursor Agent
@@ -18,6 +25,12 @@ type LokiClient struct {
 	httpClient *http.Client
 	username   string
 	password   string
+	auth       LokiAuth
+	labels     LokiLabelMapping
+	// maxRetries and retryBackoff control retries of failed requests.
+	maxRetries   int
+	retryBackoff time.Duration
+	budget       *queryBudget
 }
 
 // LokiQueryResponse represents the response from Loki query API
@@ -46,6 +59,9 @@ func NewLokiClient(baseURL string) *LokiClient {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		labels:       DefaultLokiLabelMapping(),
+		maxRetries:   defaultLokiMaxRetries,
+		retryBackoff: defaultLokiRetryBackoff,
 	}
 }
 
@@ -56,37 +72,228 @@ func NewLokiClientWithAuth(baseURL, instanceID, apiKey string) *LokiClient {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		username: instanceID, // Use instance ID as username
-		password: apiKey,     // Use API key as password
+		username:     instanceID, // Use instance ID as username
+		password:     apiKey,     // Use API key as password
+		labels:       DefaultLokiLabelMapping(),
+		maxRetries:   defaultLokiMaxRetries,
+		retryBackoff: defaultLokiRetryBackoff,
+	}
+}
+
+// SetRetryPolicy sets how many times a failed request is retried and the initial backoff,
+// which doubles on each retry.
+func (lc *LokiClient) SetRetryPolicy(maxRetries int, backoff time.Duration) {
+	lc.maxRetries = maxRetries
+	lc.retryBackoff = backoff
+}
+
+// SetQueryBudget limits the number of requests, including pages and retries, made until
+// the budget is reset. A limit of zero is unlimited.
+func (lc *LokiClient) SetQueryBudget(limit int) {
+	lc.budget = &queryBudget{limit: int64(limit)}
+}
+
+// ResetQueryBudget makes the full query budget available again.
+func (lc *LokiClient) ResetQueryBudget() {
+	if lc.budget != nil {
+		lc.budget.used.Store(0)
+	}
+}
+
+// SetAuth sets the bearer token, tenant, custom headers, and TLS settings used for requests.
+func (lc *LokiClient) SetAuth(auth LokiAuth) {
+	lc.auth = auth
+	if auth.TLS != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = auth.TLS
+		lc.httpClient.Transport = transport
 	}
 }
 
+// SetLabelMapping sets the labels used to query check results and read their status and reason.
+func (lc *LokiClient) SetLabelMapping(mapping LokiLabelMapping) {
+	lc.labels = mapping
+}
+
+const (
+	lokiDirectionBackward = "backward"
+	lokiDirectionForward  = "forward"
+	// defaultLokiPageSize is the number of entries requested per page when paging through a window.
+	defaultLokiPageSize = 1000
+	// maxLokiTimestampLimit bounds the limit used to fetch every entry sharing one timestamp.
+	maxLokiTimestampLimit = 100000
+	// defaultLokiWindow matches the range Loki queries when no start is given.
+	defaultLokiWindow = time.Hour
+	// Defaults for retrying failed requests and the longest wait between attempts.
+	defaultLokiMaxRetries   = 3
+	defaultLokiRetryBackoff = time.Second
+	maxLokiRetryWait        = 5 * time.Minute
+	// healthCheckTimeout bounds the requests made by an evidence source health check.
+	healthCheckTimeout = time.Minute
+)
+
+// errQueryBudgetExceeded is returned once a run has made all the requests its budget allows.
+var errQueryBudgetExceeded = errors.New("Loki query budget exceeded")
+
+// LokiRangeOptions controls how QueryLogsInRange pages through a time window.
+type LokiRangeOptions struct {
+	// Start and End bound the window. A zero End is now and a zero Start is one hour before End.
+	Start time.Time
+	End   time.Time
+	// Direction is backward (newest first) or forward (oldest first). Defaults to backward.
+	Direction string
+	// PageSize is the number of entries requested per page. Defaults to 1000.
+	PageSize int
+}
+
 // QueryLogs queries Loki for logs matching the given policy ID
 func (lc *LokiClient) QueryLogs(ctx context.Context, policyID string, limit int) ([]LokiLogEntry, error) {
+	return lc.queryPage(ctx, policyID, time.Time{}, time.Time{}, "", limit)
+}
+
+// QueryLogsInRange queries Loki for all logs matching the given policy ID in the window,
+// requesting a page at a time until the window is exhausted.
+func (lc *LokiClient) QueryLogsInRange(ctx context.Context, policyID string, opts LokiRangeOptions) ([]LokiLogEntry, error) {
+	end := opts.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	start := opts.Start
+	if start.IsZero() {
+		start = end.Add(-defaultLokiWindow)
+	}
+	direction := opts.Direction
+	if direction == "" {
+		direction = lokiDirectionBackward
+	}
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultLokiPageSize
+	}
+
+	var entries []LokiLogEntry
+	// Pages overlap by one nanosecond so entries sharing the boundary timestamp are not
+	// lost; seen drops the entries that were already returned.
+	seen := make(map[string]struct{})
+	add := func(batch []LokiLogEntry) {
+		for _, entry := range batch {
+			key := entry.Timestamp + "\x00" + entry.Message + "\x00" + fmt.Sprint(entry.Labels)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			entries = append(entries, entry)
+		}
+	}
+	for page := 1; ; page++ {
+		batch, err := lc.queryPage(ctx, policyID, start, end, direction, pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page, err)
+		}
+		sortEntries(batch, direction)
+		add(batch)
+		if len(batch) < pageSize {
+			return entries, nil
+		}
+
+		// Move the window past the last entry of the page. A page whose entries all share the
+		// boundary timestamp cannot move the window, so every entry at that timestamp is
+		// fetched on its own and the window moves past it.
+		last := lokiTimestamp(batch[len(batch)-1].Timestamp)
+		if direction == lokiDirectionForward {
+			if last.After(start) {
+				start = last
+			} else {
+				atLast, err := lc.queryTimestamp(ctx, policyID, last, pageSize)
+				if err != nil {
+					return nil, fmt.Errorf("page %d: %w", page, err)
+				}
+				add(atLast)
+				start = last.Add(time.Nanosecond)
+				if !start.Before(end) {
+					return entries, nil
+				}
+			}
+		} else {
+			if next := last.Add(time.Nanosecond); next.Before(end) {
+				end = next
+			} else {
+				atLast, err := lc.queryTimestamp(ctx, policyID, last, pageSize)
+				if err != nil {
+					return nil, fmt.Errorf("page %d: %w", page, err)
+				}
+				add(atLast)
+				end = last
+				if !end.After(start) {
+					return entries, nil
+				}
+			}
+		}
+		logger.Debug("Fetching next Loki page", "policy_id", policyID, "page", page+1, "entries", len(entries))
+	}
+}
+
+// queryTimestamp returns every entry at exactly timestamp ts, doubling the limit from
+// pageSize until a response is not full. It returns an error rather than a partial result
+// if more than maxLokiTimestampLimit entries share the timestamp.
+func (lc *LokiClient) queryTimestamp(ctx context.Context, policyID string, ts time.Time, pageSize int) ([]LokiLogEntry, error) {
+	for limit := pageSize * 2; ; limit *= 2 {
+		limit = min(limit, maxLokiTimestampLimit)
+		batch, err := lc.queryPage(ctx, policyID, ts, ts.Add(time.Nanosecond), lokiDirectionForward, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) < limit {
+			return batch, nil
+		}
+		if limit >= maxLokiTimestampLimit {
+			return nil, fmt.Errorf("more than %d entries share timestamp %s", maxLokiTimestampLimit, ts.Format(time.RFC3339Nano))
+		}
+		logger.Debug("Fetching entries sharing one timestamp", "policy_id", policyID, "timestamp", ts.Format(time.RFC3339Nano), "limit", limit*2)
+	}
+}
+
+// sortEntries orders entries from all streams of a page in the query direction.
+func sortEntries(entries []LokiLogEntry, direction string) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		ti, tj := lokiTimestamp(entries[i].Timestamp), lokiTimestamp(entries[j].Timestamp)
+		if direction == lokiDirectionForward {
+			return ti.Before(tj)
+		}
+		return ti.After(tj)
+	})
+}
+
+// queryPage requests a single page of logs matching the given policy ID. A zero start or end
+// and an empty direction use the Loki defaults.
+func (lc *LokiClient) queryPage(ctx context.Context, policyID string, start, end time.Time, direction string, limit int) ([]LokiLogEntry, error) {
 	// Construct the query URL
 	queryURL := fmt.Sprintf("%s/loki/api/v1/query_range", lc.baseURL)
 
 	// Create query parameters
 	params := url.Values{}
-	params.Set("query", fmt.Sprintf(`{service_name=~".+"} | policy_id="%s"`, policyID))
+	query, err := lc.labels.query(policyID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query for policy %q: %w", policyID, err)
+	}
+	params.Set("query", query)
 	params.Set("limit", strconv.Itoa(limit))
+	if !start.IsZero() {
+		params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	}
+	if !end.IsZero() {
+		params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	}
+	if direction != "" {
+		params.Set("direction", direction)
+	}
 
 	fullURL := fmt.Sprintf("%s?%s", queryURL, params.Encode())
 
 	// Make the HTTP request
-	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	resp, err := lc.get(ctx, fullURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Add authentication headers (basic auth with instance ID and API key)
-	if lc.username != "" && lc.password != "" {
-		req.SetBasicAuth(lc.username, lc.password)
-	}
-
-	resp, err := lc.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -117,3 +324,388 @@ func (lc *LokiClient) QueryLogs(ctx context.Context, policyID string, limit int)
 
 	return entries, nil
 }
+
+// CheckHealth verifies that Loki is ready and accepts the configured credentials by
+// requesting /ready and the label names. Gateways that do not expose /ready, such as
+// Grafana Cloud, are checked with the label query only.
+func (lc *LokiClient) CheckHealth(ctx context.Context) error {
+	resp, err := lc.get(ctx, fmt.Sprintf("%s/ready", lc.baseURL))
+	if err != nil {
+		return fmt.Errorf("Loki is not reachable at %s: %w", lc.baseURL, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		resp.Body.Close()
+	case http.StatusNotFound:
+		resp.Body.Close()
+		logger.Debug("Loki does not expose /ready, checking the label API only", "url", lc.baseURL)
+	default:
+		return lokiStatusError(resp, "Loki is not ready")
+	}
+
+	params := url.Values{}
+	params.Set("start", strconv.FormatInt(time.Now().Add(-defaultLokiWindow).UnixNano(), 10))
+	resp, err = lc.get(ctx, fmt.Sprintf("%s/loki/api/v1/labels?%s", lc.baseURL, params.Encode()))
+	if err != nil {
+		return fmt.Errorf("Loki label query failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return lokiStatusError(resp, "Loki label query failed")
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// lokiStatusError closes the response and describes an unexpected status, calling out
+// rejected credentials.
+func lokiStatusError(resp *http.Response, message string) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	detail := strings.TrimSpace(string(body))
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		message = "Loki rejected the configured credentials"
+	}
+	if detail == "" {
+		return fmt.Errorf("%s: status %d", message, resp.StatusCode)
+	}
+	return fmt.Errorf("%s: status %d: %s", message, resp.StatusCode, detail)
+}
+
+// get sends a GET request, retrying network errors, 429 and 5xx responses with exponential
+// backoff. A Retry-After header on the response takes precedence over the backoff. Every
+// attempt counts against the query budget.
+func (lc *LokiClient) get(ctx context.Context, fullURL string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if !lc.budget.take() {
+			return nil, errQueryBudgetExceeded
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		// Add authentication headers (basic auth with instance ID and API key)
+		if lc.username != "" && lc.password != "" {
+			req.SetBasicAuth(lc.username, lc.password)
+		}
+		if err := lc.auth.apply(req); err != nil {
+			return nil, err
+		}
+
+		var wait time.Duration
+		resp, err := lc.httpClient.Do(req)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			err = fmt.Errorf("failed to execute request: %w", err)
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+			wait = retryAfter(resp.Header.Get("Retry-After"), time.Now())
+			resp.Body.Close()
+			err = fmt.Errorf("Loki API returned status %d", resp.StatusCode)
+		default:
+			return resp, nil
+		}
+
+		if attempt >= lc.maxRetries {
+			if attempt > 0 {
+				return nil, fmt.Errorf("%w after %d attempts", err, attempt+1)
+			}
+			return nil, err
+		}
+		if wait <= 0 {
+			wait = min(lc.retryBackoff<<attempt, maxLokiRetryWait)
+		}
+		wait = min(wait, maxLokiRetryWait)
+		logger.Warn("Retrying Loki query", "error", err, "attempt", attempt+1, "wait", wait.String())
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+// It returns zero if the header is missing or invalid.
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// queryBudget limits the number of requests made to Loki in a run. A nil budget or a
+// limit of zero is unlimited.
+type queryBudget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+func (b *queryBudget) take() bool {
+	if b == nil || b.limit <= 0 {
+		return true
+	}
+	return b.used.Add(1) <= b.limit
+}
+
+// lokiSource is an EvidenceSource that reads policy results logged to Loki.
+type lokiSource struct {
+	client    *LokiClient
+	direction string
+	pageSize  int
+	// grafanaURL and grafanaDatasource enable Grafana Explore evidence links.
+	grafanaURL        string
+	grafanaDatasource string
+}
+
+// newLokiSource creates a Loki evidence source, preferring Grafana Cloud over a local Loki.
+func newLokiSource(config Config) (*lokiSource, error) {
+	var client *LokiClient
+	switch {
+	case config.GrafanaCloudEndpoint != "":
+		client = NewLokiClientWithAuth(
+			config.GrafanaCloudEndpoint,
+			config.GrafanaCloudInstanceID,
+			config.GrafanaCloudAPIKey,
+		)
+		logger.Info("Initialized Loki client with Grafana Cloud endpoint")
+	case config.LokiURL != "":
+		client = NewLokiClient(config.LokiURL)
+		logger.Info("Initialized Loki client with local endpoint")
+	default:
+		return nil, errors.New("either loki-url or grafana-cloud-endpoint must be provided")
+	}
+
+	auth, err := newLokiAuth(config)
+	if err != nil {
+		return nil, err
+	}
+	client.SetAuth(auth)
+
+	mapping, err := newLokiLabelMapping(config)
+	if err != nil {
+		return nil, err
+	}
+	client.SetLabelMapping(mapping)
+	client.SetRetryPolicy(config.LokiMaxRetryCount(), config.LokiRetryBackoffDuration())
+	client.SetQueryBudget(config.LokiQueryBudgetLimit())
+
+	return &lokiSource{
+		client:            client,
+		direction:         config.LokiQueryDirection(),
+		pageSize:          config.LokiQueryPageSize(),
+		grafanaURL:        config.GrafanaURL,
+		grafanaDatasource: config.GrafanaDatasourceUID,
+	}, nil
+}
+
+// startRun resets the query budget for a new GetResults run.
+func (l *lokiSource) startRun() {
+	l.client.ResetQueryBudget()
+}
+
+func (l *lokiSource) checkHealth(ctx context.Context) error {
+	return l.client.CheckHealth(ctx)
+}
+
+// describeQuery returns the LogQL query and the first query_range request for a check.
+func (l *lokiSource) describeQuery(checkID string, window TimeWindow) ([]any, error) {
+	query, err := l.client.labels.query(checkID)
+	if err != nil {
+		return nil, err
+	}
+	end := window.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	start := window.Start
+	if start.IsZero() {
+		start = end.Add(-defaultLokiWindow)
+	}
+	return []any{
+		"query", query,
+		"url", queryRangeURL(l.client.baseURL, query, start, end),
+		"direction", l.direction,
+		"page_size", l.pageSize,
+	}, nil
+}
+
+func (l *lokiSource) Name() string {
+	return evidenceSourceLoki
+}
+
+func (l *lokiSource) Query(ctx context.Context, checkID string, window TimeWindow) ([]EvidenceRecord, error) {
+	entries, err := l.client.QueryLogsInRange(ctx, checkID, LokiRangeOptions{
+		Start:     window.Start,
+		End:       window.End,
+		Direction: l.direction,
+		PageSize:  l.pageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := l.client.labels.query(checkID)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]EvidenceRecord, 0, len(entries))
+	for _, entry := range entries {
+		records = append(records, l.record(checkID, query, entry))
+	}
+	return records, nil
+}
+
+// record converts a log entry to evidence. Labels from the label mapping take precedence;
+// otherwise the result, reason, and time of OCSF bodies logged by ProofWatch are used.
+func (l *lokiSource) record(checkID, query string, entry LokiLogEntry) EvidenceRecord {
+	labels := l.client.labels
+	event, structured := parseOCSFEvent(entry.Message)
+	resource := lokiResource(entry.Labels, event)
+	record := EvidenceRecord{
+		CheckID:      checkID,
+		Timestamp:    lokiTimestamp(entry.Timestamp),
+		RunID:        lokiRunID(entry.Labels),
+		SubjectID:    resource,
+		SubjectTitle: resource,
+		Attributes:   lokiAttributes(entry.Labels, event),
+		Result:       labels.result(entry.Labels),
+		Reason:       labels.reason(entry.Labels),
+		Props: []policy.Property{
+			{Name: "timestamp", Value: entry.Timestamp},
+			{Name: "labels", Value: formatLabels(entry.Labels)},
+		},
+		Links: l.evidenceLinks(query, entry),
+	}
+	if raw, err := json.Marshal(entry); err == nil {
+		record.Raw = raw
+	}
+	if !structured {
+		record.Props = append(record.Props, policy.Property{Name: "message", Value: entry.Message})
+		return record
+	}
+
+	record.Summary = event.summary()
+	if t := event.time(); !t.IsZero() {
+		record.Timestamp = t
+	}
+	if _, ok := entry.Labels[labels.StatusField]; !ok {
+		if result, ok := event.result(); ok {
+			record.Result = result
+		}
+	}
+	if _, ok := entry.Labels[labels.ReasonField]; !ok && event.reason() != "" {
+		record.Reason = event.reason()
+	}
+	record.Props = append(record.Props, event.props()...)
+	return record
+}
+
+// lokiAttributes returns the labels of a log entry and the observables of its OCSF body,
+// such as file.name, with labels taking precedence.
+func lokiAttributes(labels map[string]string, event ocsfEvent) map[string]string {
+	attributes := make(map[string]string, len(labels)+len(event.Observables))
+	for _, observable := range event.Observables {
+		if observable.Value != "" {
+			attributes[observable.Name] = observable.Value
+		}
+	}
+	for name, value := range labels {
+		attributes[name] = value
+	}
+	return attributes
+}
+
+// formatLabels formats labels as a LogQL stream selector with the names sorted.
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+quoteLogQL(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// evidenceLinks returns the links to a log entry: a Loki query_range URL and, when
+// grafana-url and grafana-datasource-uid are configured, a Grafana Explore link.
+func (l *lokiSource) evidenceLinks(query string, entry LokiLogEntry) []policy.Link {
+	at := lokiTimestamp(entry.Timestamp)
+	links := []policy.Link{
+		{
+			Description: fmt.Sprintf("Evidence from Loki log entry at %s", entry.Timestamp),
+			Href:        queryRangeURL(l.client.baseURL, query, at, at),
+		},
+	}
+	if l.grafanaURL != "" && l.grafanaDatasource != "" {
+		href, err := grafanaExploreURL(l.grafanaURL, l.grafanaDatasource, query, at, at)
+		if err != nil {
+			logger.Warn("Unable to create Grafana Explore link", "error", err)
+			return links
+		}
+		links = append(links, policy.Link{
+			Description: fmt.Sprintf("Grafana Explore view of Loki log entry at %s", entry.Timestamp),
+			Href:        href,
+		})
+	}
+	return links
+}
+
+// Labels that carry the evaluation run of a log entry, in order of preference.
+var runLabels = []string{"run_id", "trace_id"}
+
+// Labels that identify the resource a log entry is about, in order of preference.
+var resourceLabels = []string{"resource_id", "resource", "repository", "bucket", "bucket_name", "file_name", "filename"}
+
+// Observables in the OCSF log body that identify the resource, in order of preference.
+var resourceObservables = []string{"resource.uid", "resource.name", "file.name"}
+
+// unknownResource is the subject for log entries that do not identify a resource.
+const unknownResource = "unknown"
+
+// lokiRunID returns the evaluation run of a log entry, or an empty string if it has none.
+func lokiRunID(labels map[string]string) string {
+	for _, name := range runLabels {
+		if value := labels[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// lokiResource returns the resource a log entry is about from its labels or, for OCSF
+// bodies logged by ProofWatch, the observables in the body such as the file.name written
+// by conftest-exporter.
+func lokiResource(labels map[string]string, event ocsfEvent) string {
+	for _, name := range resourceLabels {
+		if value := labels[name]; value != "" {
+			return value
+		}
+	}
+	if value := event.observable(resourceObservables...); value != "" {
+		return value
+	}
+	return unknownResource
+}
+
+// lokiTimestamp parses a Loki timestamp in Unix nanoseconds, returning the zero time if it is invalid.
+func lokiTimestamp(value string) time.Time {
+	ns, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLokiEntry is a log line stored by fakeLoki.
+type fakeLokiEntry struct {
+	ts   int64
+	line string
+	// labels are the stream labels of the entry, policy_id="check-1" if unset.
+	labels map[string]string
+}
+
+// fakeLoki serves query_range like Loki: start is inclusive, end is exclusive, and at most
+// limit entries are returned in the requested direction.
+type fakeLoki struct {
+	mu       sync.Mutex
+	entries  []fakeLokiEntry
+	requests int
+	// fail returns a status and Retry-After header for the nth request, or 0 to serve it.
+	fail func(n int) (int, string)
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	if f.fail != nil {
+		if status, retryAfter := f.fail(f.requests); status != 0 {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+	}
+	if r.URL.Path != "/loki/api/v1/query_range" {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	start, _ := strconv.ParseInt(query.Get("start"), 10, 64)
+	end, _ := strconv.ParseInt(query.Get("end"), 10, 64)
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	forward := query.Get("direction") == lokiDirectionForward
+
+	var matched []fakeLokiEntry
+	for _, entry := range f.entries {
+		if entry.ts >= start && entry.ts < end {
+			matched = append(matched, entry)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if forward {
+			return matched[i].ts < matched[j].ts
+		}
+		return matched[i].ts > matched[j].ts
+	})
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+
+	var resp LokiQueryResponse
+	resp.Status = "success"
+	resp.Data.ResultType = "streams"
+	for _, entry := range matched {
+		labels := entry.labels
+		if labels == nil {
+			labels = map[string]string{"policy_id": "check-1"}
+		}
+		resp.Data.Result = append(resp.Data.Result, struct {
+			Stream map[string]string `json:"stream"`
+			Values [][]string        `json:"values"`
+		}{Stream: labels, Values: [][]string{{strconv.FormatInt(entry.ts, 10), entry.line}}})
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func newFakeLokiClient(t *testing.T, loki *fakeLoki) *LokiClient {
+	t.Helper()
+	server := httptest.NewServer(loki)
+	t.Cleanup(server.Close)
+	client := NewLokiClient(server.URL)
+	client.SetRetryPolicy(2, time.Millisecond)
+	return client
+}
+
+// fakeEntries creates count entries starting at base, advancing the timestamp every
+// perTimestamp entries.
+func fakeEntries(base time.Time, count, perTimestamp int) []fakeLokiEntry {
+	entries := make([]fakeLokiEntry, 0, count)
+	for i := 0; i < count; i++ {
+		entries = append(entries, fakeLokiEntry{
+			ts:   base.Add(time.Duration(i/perTimestamp) * time.Second).UnixNano(),
+			line: fmt.Sprintf("entry %d", i),
+		})
+	}
+	return entries
+}
+
+func TestQueryLogsInRangePaging(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	window := LokiRangeOptions{Start: base.Add(-time.Minute), End: base.Add(time.Hour), PageSize: 4}
+
+	tests := []struct {
+		name         string
+		count        int
+		perTimestamp int
+	}{
+		{name: "distinct timestamps", count: 25, perTimestamp: 1},
+		{name: "shared timestamps within a page", count: 25, perTimestamp: 3},
+		{name: "shared timestamps across pages", count: 25, perTimestamp: 6},
+		{name: "one timestamp", count: 25, perTimestamp: 25},
+		{name: "exact pages", count: 24, perTimestamp: 1},
+	}
+	for _, tt := range tests {
+		for _, direction := range []string{lokiDirectionBackward, lokiDirectionForward} {
+			t.Run(tt.name+"/"+direction, func(t *testing.T) {
+				loki := &fakeLoki{entries: fakeEntries(base, tt.count, tt.perTimestamp)}
+				client := newFakeLokiClient(t, loki)
+
+				opts := window
+				opts.Direction = direction
+				entries, err := client.QueryLogsInRange(context.Background(), "check-1", opts)
+				if err != nil {
+					t.Fatalf("QueryLogsInRange() error = %v", err)
+				}
+				if len(entries) != tt.count {
+					t.Fatalf("QueryLogsInRange() returned %d entries, want %d", len(entries), tt.count)
+				}
+				lines := make(map[string]struct{}, len(entries))
+				for _, entry := range entries {
+					if _, ok := lines[entry.Message]; ok {
+						t.Errorf("entry %q returned more than once", entry.Message)
+					}
+					lines[entry.Message] = struct{}{}
+				}
+				for i := 1; i < len(entries); i++ {
+					prev, cur := lokiTimestamp(entries[i-1].Timestamp), lokiTimestamp(entries[i].Timestamp)
+					if direction == lokiDirectionForward && cur.Before(prev) || direction == lokiDirectionBackward && cur.After(prev) {
+						t.Fatalf("entries are not in %s order at %d: %s then %s", direction, i, prev, cur)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestQueryLogsInRangeWindowBounds(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	loki := &fakeLoki{entries: []fakeLokiEntry{
+		{ts: base.Add(-time.Nanosecond).UnixNano(), line: "before"},
+		{ts: base.UnixNano(), line: "start"},
+		{ts: base.Add(time.Hour).UnixNano(), line: "end"},
+		{ts: base.Add(time.Hour + time.Nanosecond).UnixNano(), line: "after"},
+	}}
+	client := newFakeLokiClient(t, loki)
+
+	entries, err := client.QueryLogsInRange(context.Background(), "check-1", LokiRangeOptions{
+		Start: base,
+		End:   base.Add(time.Hour + time.Nanosecond),
+	})
+	if err != nil {
+		t.Fatalf("QueryLogsInRange() error = %v", err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Message)
+	}
+	if fmt.Sprint(got) != "[end start]" {
+		t.Errorf("QueryLogsInRange() = %v, want [end start]", got)
+	}
+}
+
+func TestQueryLogsInRangeEmptyWindow(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	loki := &fakeLoki{entries: fakeEntries(base.Add(-2*time.Hour), 5, 1)}
+	client := newFakeLokiClient(t, loki)
+
+	entries, err := client.QueryLogsInRange(context.Background(), "check-1", LokiRangeOptions{
+		Start:    base,
+		End:      base.Add(time.Hour),
+		PageSize: 4,
+	})
+	if err != nil {
+		t.Fatalf("QueryLogsInRange() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("QueryLogsInRange() returned %d entries, want none", len(entries))
+	}
+	if loki.requests != 1 {
+		t.Errorf("QueryLogsInRange() made %d requests, want 1", loki.requests)
+	}
+}
+
+func TestQueryLogsInRangeRetries(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	window := LokiRangeOptions{Start: base, End: base.Add(time.Hour)}
+
+	tests := []struct {
+		name         string
+		fail         func(n int) (int, string)
+		wantErr      bool
+		wantRequests int
+		minElapsed   time.Duration
+	}{
+		{
+			name:         "server error",
+			fail:         func(n int) (int, string) { return failFirst(n, 2, http.StatusServiceUnavailable, "") },
+			wantRequests: 3,
+		},
+		{
+			name:         "rate limited with Retry-After",
+			fail:         func(n int) (int, string) { return failFirst(n, 1, http.StatusTooManyRequests, "1") },
+			wantRequests: 2,
+			minElapsed:   time.Second,
+		},
+		{
+			name:         "retries exhausted",
+			fail:         func(n int) (int, string) { return http.StatusBadGateway, "" },
+			wantErr:      true,
+			wantRequests: 3,
+		},
+		{
+			name:         "client error is not retried",
+			fail:         func(n int) (int, string) { return http.StatusBadRequest, "" },
+			wantErr:      true,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loki := &fakeLoki{entries: fakeEntries(base, 3, 1), fail: tt.fail}
+			client := newFakeLokiClient(t, loki)
+
+			started := time.Now()
+			entries, err := client.QueryLogsInRange(context.Background(), "check-1", window)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("QueryLogsInRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(entries) != 3 {
+				t.Errorf("QueryLogsInRange() returned %d entries, want 3", len(entries))
+			}
+			if loki.requests != tt.wantRequests {
+				t.Errorf("QueryLogsInRange() made %d requests, want %d", loki.requests, tt.wantRequests)
+			}
+			if elapsed := time.Since(started); elapsed < tt.minElapsed {
+				t.Errorf("QueryLogsInRange() returned after %s, want at least %s", elapsed, tt.minElapsed)
+			}
+		})
+	}
+}
+
+// failFirst fails the first count requests with status.
+func failFirst(n, count, status int, retryAfter string) (int, string) {
+	if n <= count {
+		return status, retryAfter
+	}
+	return 0, ""
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "3", want: 3 * time.Second},
+		{value: "0", want: 0},
+		{value: "-1", want: 0},
+		{value: "soon", want: 0},
+		{value: now.Add(10 * time.Second).Format(http.TimeFormat), want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.value, now); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestQueryLogsInRangeBudget(t *testing.T) {
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	window := LokiRangeOptions{Start: base, End: base.Add(time.Hour), PageSize: 4}
+
+	t.Run("exceeded", func(t *testing.T) {
+		loki := &fakeLoki{entries: fakeEntries(base, 25, 1)}
+		client := newFakeLokiClient(t, loki)
+		client.SetQueryBudget(3)
+
+		_, err := client.QueryLogsInRange(context.Background(), "check-1", window)
+		if !errors.Is(err, errQueryBudgetExceeded) {
+			t.Fatalf("QueryLogsInRange() error = %v, want %v", err, errQueryBudgetExceeded)
+		}
+		if loki.requests != 3 {
+			t.Errorf("QueryLogsInRange() made %d requests, want 3", loki.requests)
+		}
+	})
+
+	t.Run("retries count against the budget", func(t *testing.T) {
+		loki := &fakeLoki{
+			entries: fakeEntries(base, 3, 1),
+			fail:    func(n int) (int, string) { return failFirst(n, 1, http.StatusServiceUnavailable, "") },
+		}
+		client := newFakeLokiClient(t, loki)
+		client.SetQueryBudget(1)
+
+		_, err := client.QueryLogsInRange(context.Background(), "check-1", window)
+		if !errors.Is(err, errQueryBudgetExceeded) {
+			t.Fatalf("QueryLogsInRange() error = %v, want %v", err, errQueryBudgetExceeded)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		loki := &fakeLoki{entries: fakeEntries(base, 3, 1)}
+		client := newFakeLokiClient(t, loki)
+		client.SetQueryBudget(1)
+
+		for i := 0; i < 2; i++ {
+			if _, err := client.QueryLogsInRange(context.Background(), "check-1", window); err != nil {
+				t.Fatalf("QueryLogsInRange() run %d error = %v", i+1, err)
+			}
+			client.ResetQueryBudget()
+		}
+	})
+}
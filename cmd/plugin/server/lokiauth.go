@@ -0,0 +1,178 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// lokiOrgIDHeader is the header Loki reads the tenant from in multi-tenant mode.
+const lokiOrgIDHeader = "X-Scope-OrgID"
+
+// lokiHeaderNamePattern matches the token characters allowed in HTTP header names.
+var lokiHeaderNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// LokiAuth holds the credentials and transport settings used for Loki requests in
+// addition to Grafana Cloud basic auth.
+type LokiAuth struct {
+	// BearerToken is sent in the Authorization header.
+	BearerToken string
+	// BearerTokenFile is read on every request so rotated tokens are picked up.
+	// It is ignored when BearerToken is set.
+	BearerTokenFile string
+	// OrgIDs are the tenants queried. Multiple tenants are sent as a single
+	// X-Scope-OrgID header separated by "|".
+	OrgIDs []string
+	// Headers are added to every request.
+	Headers http.Header
+	// TLS configures client certificates and trusted CAs. Nil uses the defaults.
+	TLS *tls.Config
+}
+
+// newLokiAuth creates the Loki authentication settings from the plugin configuration.
+func newLokiAuth(config Config) (LokiAuth, error) {
+	var auth LokiAuth
+	var errs []error
+
+	if config.LokiBearerToken != "" && config.LokiBearerTokenFile != "" {
+		errs = append(errs, errors.New("only one of loki-bearer-token and loki-bearer-token-file can be provided"))
+	}
+	if (config.LokiBearerToken != "" || config.LokiBearerTokenFile != "") && config.GrafanaCloudEndpoint != "" {
+		errs = append(errs, errors.New("loki bearer tokens cannot be used with grafana-cloud-endpoint, which uses basic auth"))
+	}
+	auth.BearerToken = config.LokiBearerToken
+	if config.LokiBearerTokenFile != "" {
+		auth.BearerTokenFile = filepath.Clean(config.LokiBearerTokenFile)
+		if _, err := readBearerToken(auth.BearerTokenFile); err != nil {
+			errs = append(errs, fmt.Errorf("invalid loki-bearer-token-file: %w", err))
+		}
+	}
+
+	if config.LokiOrgID != "" {
+		for _, orgID := range strings.Split(config.LokiOrgID, ",") {
+			orgID = strings.TrimSpace(orgID)
+			if orgID == "" || strings.Contains(orgID, "|") || strings.ContainsAny(orgID, "\r\n") {
+				errs = append(errs, fmt.Errorf("invalid loki-org-id %q: must be a comma separated list of tenant IDs", config.LokiOrgID))
+				break
+			}
+			auth.OrgIDs = append(auth.OrgIDs, orgID)
+		}
+	}
+
+	headers, err := parseHeaders(config.LokiHeaders)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid loki-headers: %w", err))
+	}
+	if headers.Get("Authorization") != "" && (auth.BearerToken != "" || auth.BearerTokenFile != "" || config.GrafanaCloudEndpoint != "") {
+		errs = append(errs, errors.New("loki-headers cannot set Authorization when a bearer token or grafana-cloud-endpoint is configured"))
+	}
+	if headers.Get(lokiOrgIDHeader) != "" && len(auth.OrgIDs) > 0 {
+		errs = append(errs, fmt.Errorf("loki-headers cannot set %s when loki-org-id is provided", lokiOrgIDHeader))
+	}
+	auth.Headers = headers
+
+	tlsConfig, err := lokiTLSConfig(config)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	auth.TLS = tlsConfig
+
+	return auth, errors.Join(errs...)
+}
+
+// parseHeaders parses a comma separated list of Name=value pairs such as
+// X-Custom=one,X-Other=two.
+func parseHeaders(value string) (http.Header, error) {
+	headers := make(http.Header)
+	if value == "" {
+		return headers, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		name, headerValue, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		name = strings.TrimSpace(name)
+		if !ok || !lokiHeaderNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%q must be a Name=value pair", pair)
+		}
+		if strings.ContainsAny(headerValue, "\r\n") {
+			return nil, fmt.Errorf("value of header %s contains line breaks", name)
+		}
+		headers.Add(name, strings.TrimSpace(headerValue))
+	}
+	return headers, nil
+}
+
+// lokiTLSConfig loads the client certificate and CA bundle. It returns nil when
+// neither is configured so the default transport is used.
+func lokiTLSConfig(config Config) (*tls.Config, error) {
+	if config.LokiCACert == "" && config.LokiClientCert == "" && config.LokiClientKey == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if (config.LokiClientCert == "") != (config.LokiClientKey == "") {
+		return nil, errors.New("loki-client-cert and loki-client-key must be provided together")
+	}
+	if config.LokiClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(filepath.Clean(config.LokiClientCert), filepath.Clean(config.LokiClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid loki-client-cert or loki-client-key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.LokiCACert != "" {
+		pem, err := os.ReadFile(filepath.Clean(config.LokiCACert))
+		if err != nil {
+			return nil, fmt.Errorf("invalid loki-ca-cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid loki-ca-cert %q: no PEM encoded certificates found", config.LokiCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// readBearerToken reads a bearer token from a file, ignoring surrounding whitespace.
+func readBearerToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return token, nil
+}
+
+// apply adds the authentication headers to a request.
+func (a LokiAuth) apply(req *http.Request) error {
+	for name, values := range a.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if len(a.OrgIDs) > 0 {
+		req.Header.Set(lokiOrgIDHeader, strings.Join(a.OrgIDs, "|"))
+	}
+
+	token := a.BearerToken
+	if token == "" && a.BearerTokenFile != "" {
+		var err error
+		if token, err = readBearerToken(a.BearerTokenFile); err != nil {
+			return fmt.Errorf("failed to read bearer token: %w", err)
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// headerRecorder answers Loki queries with no results and records the request headers.
+type headerRecorder struct {
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+func (h *headerRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.headers = append(h.headers, r.Header.Clone())
+	_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "streams", "result": []}}`))
+}
+
+// last returns the headers of the most recent request.
+func (h *headerRecorder) last() http.Header {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.headers[len(h.headers)-1]
+}
+
+func TestLokiAuthHeaders(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config func(url string) Config
+		want   map[string]string
+	}{
+		{
+			name:   "no credentials",
+			config: func(url string) Config { return Config{LokiURL: url} },
+			want:   map[string]string{"Authorization": "", lokiOrgIDHeader: ""},
+		},
+		{
+			name:   "bearer token",
+			config: func(url string) Config { return Config{LokiURL: url, LokiBearerToken: "secret"} },
+			want:   map[string]string{"Authorization": "Bearer secret"},
+		},
+		{
+			name:   "bearer token file",
+			config: func(url string) Config { return Config{LokiURL: url, LokiBearerTokenFile: tokenFile} },
+			want:   map[string]string{"Authorization": "Bearer from-file"},
+		},
+		{
+			name: "grafana cloud basic auth",
+			config: func(url string) Config {
+				return Config{GrafanaCloudEndpoint: url, GrafanaCloudInstanceID: "123", GrafanaCloudAPIKey: "key"}
+			},
+			want: map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("123:key"))},
+		},
+		{
+			name: "basic auth with a tenant",
+			config: func(url string) Config {
+				return Config{GrafanaCloudEndpoint: url, GrafanaCloudInstanceID: "123", GrafanaCloudAPIKey: "key", LokiOrgID: "team-a"}
+			},
+			want: map[string]string{
+				"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("123:key")),
+				lokiOrgIDHeader: "team-a",
+			},
+		},
+		{
+			name: "custom headers and tenants",
+			config: func(url string) Config {
+				return Config{LokiURL: url, LokiHeaders: "X-Custom=one, X-Other = two=2", LokiOrgID: "team-a, team-b"}
+			},
+			want: map[string]string{
+				"Authorization": "",
+				"X-Custom":      "one",
+				"X-Other":       "two=2",
+				lokiOrgIDHeader: "team-a|team-b",
+			},
+		},
+		{
+			name:   "authorization header",
+			config: func(url string) Config { return Config{LokiURL: url, LokiHeaders: "Authorization=Token abc"} },
+			want:   map[string]string{"Authorization": "Token abc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &headerRecorder{}
+			server := httptest.NewServer(recorder)
+			t.Cleanup(server.Close)
+
+			source, err := newLokiSource(tt.config(server.URL))
+			if err != nil {
+				t.Fatalf("newLokiSource() error = %v", err)
+			}
+			if _, err := source.client.QueryLogs(context.Background(), "check-1", 10); err != nil {
+				t.Fatalf("QueryLogs() error = %v", err)
+			}
+			headers := recorder.last()
+			for name, want := range tt.want {
+				if got := headers.Get(name); got != want {
+					t.Errorf("%s header = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("rotated bearer token file", func(t *testing.T) {
+		recorder := &headerRecorder{}
+		server := httptest.NewServer(recorder)
+		t.Cleanup(server.Close)
+
+		source, err := newLokiSource(Config{LokiURL: server.URL, LokiBearerTokenFile: tokenFile})
+		if err != nil {
+			t.Fatalf("newLokiSource() error = %v", err)
+		}
+		for _, token := range []string{"first", "second"} {
+			if err := os.WriteFile(tokenFile, []byte(token), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := source.client.QueryLogs(context.Background(), "check-1", 10); err != nil {
+				t.Fatalf("QueryLogs() error = %v", err)
+			}
+			if got := recorder.last().Get("Authorization"); got != "Bearer "+token {
+				t.Errorf("Authorization header = %q, want %q", got, "Bearer "+token)
+			}
+		}
+
+		if err := os.WriteFile(tokenFile, []byte(" \n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := source.client.QueryLogs(context.Background(), "check-1", 10); err == nil || !strings.Contains(err.Error(), "failed to read bearer token") {
+			t.Errorf("QueryLogs() with an empty token file error = %v, want a bearer token error", err)
+		}
+	})
+}
+
+func TestNewLokiAuthErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"token":      "secret",
+		"empty":      "\n",
+		"ca.pem":     "not a certificate",
+		"client.pem": "not a certificate",
+	})
+	token := filepath.Join(dir, "token")
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{
+			name:    "token and token file",
+			config:  Config{LokiBearerToken: "secret", LokiBearerTokenFile: token},
+			wantErr: "only one of loki-bearer-token and loki-bearer-token-file can be provided",
+		},
+		{
+			name:    "token with grafana cloud",
+			config:  Config{LokiBearerToken: "secret", GrafanaCloudEndpoint: "https://logs.example.com"},
+			wantErr: "loki bearer tokens cannot be used with grafana-cloud-endpoint",
+		},
+		{
+			name:    "missing token file",
+			config:  Config{LokiBearerTokenFile: filepath.Join(dir, "missing")},
+			wantErr: "invalid loki-bearer-token-file",
+		},
+		{
+			name:    "empty token file",
+			config:  Config{LokiBearerTokenFile: filepath.Join(dir, "empty")},
+			wantErr: "is empty",
+		},
+		{
+			name:    "empty tenant",
+			config:  Config{LokiOrgID: "team-a,,team-b"},
+			wantErr: `invalid loki-org-id "team-a,,team-b"`,
+		},
+		{
+			name:    "tenant with a separator",
+			config:  Config{LokiOrgID: "team-a|team-b"},
+			wantErr: "invalid loki-org-id",
+		},
+		{
+			name:    "header without a value",
+			config:  Config{LokiHeaders: "X-Custom"},
+			wantErr: `invalid loki-headers: "X-Custom" must be a Name=value pair`,
+		},
+		{
+			name:    "invalid header name",
+			config:  Config{LokiHeaders: "X Custom=one"},
+			wantErr: "must be a Name=value pair",
+		},
+		{
+			name:    "header value with a line break",
+			config:  Config{LokiHeaders: "X-Custom=one\r\nX-Injected: two"},
+			wantErr: "value of header X-Custom contains line breaks",
+		},
+		{
+			name:    "authorization header with a bearer token",
+			config:  Config{LokiBearerToken: "secret", LokiHeaders: "Authorization=Token abc"},
+			wantErr: "loki-headers cannot set Authorization",
+		},
+		{
+			name:    "tenant header with loki-org-id",
+			config:  Config{LokiOrgID: "team-a", LokiHeaders: lokiOrgIDHeader + "=team-b"},
+			wantErr: "loki-headers cannot set X-Scope-OrgID when loki-org-id is provided",
+		},
+		{
+			name:    "client cert without a key",
+			config:  Config{LokiClientCert: filepath.Join(dir, "client.pem")},
+			wantErr: "loki-client-cert and loki-client-key must be provided together",
+		},
+		{
+			name:    "invalid client cert",
+			config:  Config{LokiClientCert: filepath.Join(dir, "client.pem"), LokiClientKey: filepath.Join(dir, "client.pem")},
+			wantErr: "invalid loki-client-cert or loki-client-key",
+		},
+		{
+			name:    "invalid CA cert",
+			config:  Config{LokiCACert: filepath.Join(dir, "ca.pem")},
+			wantErr: "no PEM encoded certificates found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newLokiAuth(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newLokiAuth() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+// Defaults matching the labels written by the collector pipeline.
+const (
+	defaultLokiStreamSelector = `{service_name=~".+"}`
+	defaultLokiCheckField     = "policy_id"
+	defaultLokiStatusField    = "compliance_status"
+	defaultLokiReasonField    = "policy_status_detail"
+	defaultLokiStatusValues   = "Pass=pass,Fail=fail,Warn=warning"
+)
+
+var (
+	lokiLabelNamePattern    = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	lokiInvalidLabelPattern = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// resultsByName maps the result names accepted in loki-status-values to results.
+var resultsByName = map[string]policy.Result{
+	policy.ResultPass.String():    policy.ResultPass,
+	policy.ResultFail.String():    policy.ResultFail,
+	policy.ResultWarning.String(): policy.ResultWarning,
+	policy.ResultError.String():   policy.ResultError,
+}
+
+// LokiLabelMapping describes where the collector pipeline records check results in Loki.
+type LokiLabelMapping struct {
+	// StreamSelector selects the log streams that contain policy results.
+	StreamSelector string
+	// CheckIDField is the label that holds the check ID.
+	CheckIDField string
+	// StatusField is the label that holds the evaluation status.
+	StatusField string
+	// StatusValues maps status values, compared case-insensitively, to results.
+	// Unmapped values are reported as errors.
+	StatusValues map[string]policy.Result
+	// ReasonField is the label that holds the reason for the status.
+	ReasonField string
+}
+
+// DefaultLokiLabelMapping returns the mapping for the policy_id, compliance_status, and
+// policy_status_detail labels written by the collector pipeline.
+func DefaultLokiLabelMapping() LokiLabelMapping {
+	mapping, _ := newLokiLabelMapping(Config{})
+	return mapping
+}
+
+// newLokiLabelMapping creates the label mapping from the plugin configuration, using the
+// defaults for unset options.
+func newLokiLabelMapping(config Config) (LokiLabelMapping, error) {
+	mapping := LokiLabelMapping{
+		StreamSelector: valueOrDefault(config.LokiStreamSelector, defaultLokiStreamSelector),
+		CheckIDField:   lokiLabelName(valueOrDefault(config.LokiCheckField, defaultLokiCheckField)),
+		StatusField:    lokiLabelName(valueOrDefault(config.LokiStatusField, defaultLokiStatusField)),
+		ReasonField:    lokiLabelName(valueOrDefault(config.LokiReasonField, defaultLokiReasonField)),
+	}
+
+	selector := strings.TrimSpace(mapping.StreamSelector)
+	if !strings.HasPrefix(selector, "{") || !strings.HasSuffix(selector, "}") {
+		return mapping, fmt.Errorf("invalid loki-stream-selector %q: must be a LogQL stream selector such as %s", mapping.StreamSelector, defaultLokiStreamSelector)
+	}
+	mapping.StreamSelector = selector
+
+	for _, field := range []struct{ option, name string }{
+		{"loki-check-field", mapping.CheckIDField},
+		{"loki-status-field", mapping.StatusField},
+		{"loki-reason-field", mapping.ReasonField},
+	} {
+		if !lokiLabelNamePattern.MatchString(field.name) {
+			return mapping, fmt.Errorf("invalid %s %q: must be a label name", field.option, field.name)
+		}
+	}
+
+	values, err := parseStatusValues(valueOrDefault(config.LokiStatusValues, defaultLokiStatusValues))
+	if err != nil {
+		return mapping, fmt.Errorf("invalid loki-status-values: %w", err)
+	}
+	mapping.StatusValues = values
+	return mapping, nil
+}
+
+// parseStatusValues parses a comma separated list of status=result pairs such as Pass=pass,Skip=warning.
+func parseStatusValues(value string) (map[string]policy.Result, error) {
+	values := make(map[string]policy.Result)
+	for _, pair := range strings.Split(value, ",") {
+		status, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		status, name = strings.TrimSpace(status), strings.TrimSpace(name)
+		if !ok || status == "" {
+			return nil, fmt.Errorf("%q must be a status=result pair", pair)
+		}
+		result, ok := resultsByName[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported result %q for status %q: must be pass, fail, warning, or error", name, status)
+		}
+		values[strings.ToLower(status)] = result
+	}
+	return values, nil
+}
+
+// lokiLabelName converts an OpenTelemetry attribute name such as compliance.status to the
+// label name Loki stores it under, compliance_status.
+func lokiLabelName(name string) string {
+	return lokiInvalidLabelPattern.ReplaceAllString(strings.TrimSpace(name), "_")
+}
+
+func valueOrDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// query returns the LogQL query for the log entries of a check.
+func (m LokiLabelMapping) query(checkID string) (string, error) {
+	return buildLogQL(m.StreamSelector, logqlFilter{Label: m.CheckIDField, Value: checkID})
+}
+
+// result maps the status label of a log entry to a result.
+func (m LokiLabelMapping) result(labels map[string]string) policy.Result {
+	status, ok := labels[m.StatusField]
+	if !ok {
+		return policy.ResultError
+	}
+	if result, ok := m.StatusValues[strings.ToLower(status)]; ok {
+		return result
+	}
+	return policy.ResultError
+}
+
+// reason returns the reason label of a log entry as the reason for the OSCAL subject.
+func (m LokiLabelMapping) reason(labels map[string]string) string {
+	if reason, ok := labels[m.ReasonField]; ok && reason != "" {
+		return reason
+	}
+	// Fallback to default reason if the reason label is not available
+	return "Evidence found in Loki logs"
+}
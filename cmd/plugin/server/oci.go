@@ -0,0 +1,314 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	ociTitleAnnotation      = "org.opencontainers.image.title"
+
+	// maxBlobSize bounds the size of manifests and layers read from a registry.
+	maxBlobSize = 64 << 20
+)
+
+// ociReference identifies an artifact in an OCI registry.
+type ociReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+func (r ociReference) reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+func (r ociReference) baseURL() string {
+	scheme := "https"
+	host := r.Registry
+	if h, _, found := strings.Cut(host, ":"); found {
+		host = h
+	}
+	// Local registries are used as a stand-in for remote registries.
+	if host == "localhost" || host == "127.0.0.1" {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s", scheme, r.Registry, r.Repository)
+}
+
+func parseOCIReference(ref string) (ociReference, error) {
+	registry, remainder, found := strings.Cut(ref, "/")
+	if !found || registry == "" || remainder == "" {
+		return ociReference{}, fmt.Errorf("invalid OCI reference %q: expected <registry>/<repository>[:<tag>|@<digest>]", ref)
+	}
+
+	parsed := ociReference{Registry: registry, Tag: "latest"}
+	if repo, digest, found := strings.Cut(remainder, "@"); found {
+		parsed.Repository, parsed.Digest, parsed.Tag = repo, digest, ""
+		if !strings.HasPrefix(digest, "sha256:") {
+			return ociReference{}, fmt.Errorf("invalid OCI reference %q: only sha256 digests are supported", ref)
+		}
+	} else if idx := strings.LastIndex(remainder, ":"); idx > strings.LastIndex(remainder, "/") {
+		parsed.Repository, parsed.Tag = remainder[:idx], remainder[idx+1:]
+	} else {
+		parsed.Repository = remainder
+	}
+	return parsed, nil
+}
+
+// ociManifest is the subset of an OCI image manifest needed to pull policy layers.
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// registryClient is a minimal OCI distribution client for pulling policy artifacts.
+type registryClient struct {
+	httpClient *http.Client
+	username   string
+	password   string
+	token      string
+}
+
+func newOCISource(ref string, config Config) (PolicySource, error) {
+	parsed, err := parseOCIReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	expectedDigest := parsed.Digest
+	if config.PolicySourceDigest != "" {
+		if expectedDigest != "" && expectedDigest != config.PolicySourceDigest {
+			return nil, fmt.Errorf("policy source digest %s does not match reference digest %s", config.PolicySourceDigest, expectedDigest)
+		}
+		expectedDigest = config.PolicySourceDigest
+	}
+
+	client := &registryClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		username:   config.PolicySourceUsername,
+		password:   config.PolicySourcePassword,
+	}
+	return &fetchedSource{
+		download: func(ctx context.Context, dir string) error {
+			return client.pull(ctx, parsed, expectedDigest, dir)
+		},
+	}, nil
+}
+
+// pull downloads every layer of the referenced artifact into dir. Each blob is
+// verified against its digest and, if expectedDigest is set, the manifest must match it.
+func (rc *registryClient) pull(ctx context.Context, ref ociReference, expectedDigest, dir string) error {
+	manifestData, err := rc.get(ctx, fmt.Sprintf("%s/manifests/%s", ref.baseURL(), ref.reference()), ociManifestMediaType+", "+dockerManifestMediaType)
+	if err != nil {
+		return fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	manifestDigest := digestOf(manifestData)
+	if expectedDigest != "" && manifestDigest != expectedDigest {
+		return fmt.Errorf("manifest digest %s does not match expected %s", manifestDigest, expectedDigest)
+	}
+	logger.Info("Pulled policy manifest", "repository", ref.Repository, "digest", manifestDigest)
+
+	var manifest ociManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return fmt.Errorf("failed to decode manifest: %w", err)
+	}
+
+	for _, layer := range manifest.Layers {
+		blob, err := rc.get(ctx, fmt.Sprintf("%s/blobs/%s", ref.baseURL(), layer.Digest), "")
+		if err != nil {
+			return fmt.Errorf("failed to fetch layer %s: %w", layer.Digest, err)
+		}
+		if digest := digestOf(blob); digest != layer.Digest {
+			return fmt.Errorf("layer digest %s does not match descriptor %s", digest, layer.Digest)
+		}
+
+		if strings.Contains(layer.MediaType, "tar") {
+			if err := extractTarGz(blob, dir); err != nil {
+				return fmt.Errorf("failed to extract layer %s: %w", layer.Digest, err)
+			}
+			continue
+		}
+
+		title, ok := layer.Annotations[ociTitleAnnotation]
+		if !ok {
+			logger.Warn("Skipping policy layer without title", "digest", layer.Digest)
+			continue
+		}
+		if err := writeContained(dir, title, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rc *registryClient) get(ctx context.Context, target, accept string) ([]byte, error) {
+	resp, err := rc.do(ctx, target, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		if err := rc.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+			return nil, err
+		}
+		resp, err = rc.do(ctx, target, accept)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxBlobSize))
+}
+
+func (rc *registryClient) do(ctx context.Context, target, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	switch {
+	case rc.token != "":
+		req.Header.Set("Authorization", "Bearer "+rc.token)
+	case rc.username != "" && rc.password != "":
+		req.SetBasicAuth(rc.username, rc.password)
+	}
+	return rc.httpClient.Do(req)
+}
+
+// authenticate exchanges credentials for a bearer token using the registry's challenge.
+func (rc *registryClient) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return errors.New("registry authentication failed")
+	}
+
+	values := url.Values{}
+	var realm string
+	for _, param := range strings.Split(params, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		if key == "realm" {
+			realm = value
+			continue
+		}
+		values.Set(key, value)
+	}
+	if realm == "" {
+		return errors.New("registry authentication challenge is missing a realm")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+values.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if rc.username != "" && rc.password != "" {
+		req.SetBasicAuth(rc.username, rc.password)
+	}
+	resp, err := rc.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return fmt.Errorf("failed to decode registry token: %w", err)
+	}
+	rc.token = tokenResp.Token
+	if rc.token == "" {
+		rc.token = tokenResp.AccessToken
+	}
+	if rc.token == "" {
+		return errors.New("registry token endpoint returned an empty token")
+	}
+	return nil
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func extractTarGz(data []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, maxBlobSize))
+		if err != nil {
+			return err
+		}
+		if err := writeContained(dir, header.Name, content); err != nil {
+			return err
+		}
+	}
+}
+
+// writeContained writes data to name relative to dir, rejecting paths that escape dir.
+func writeContained(dir, name string, data []byte) error {
+	cleaned := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(name, "/")))
+	if cleaned == "." || strings.HasPrefix(cleaned, "..") {
+		return fmt.Errorf("invalid policy file path %q", name)
+	}
+	target := filepath.Join(dir, cleaned)
+	if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0600)
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarGz archives files, keyed by path, as a gzipped tarball.
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fakeRegistry serves one manifest and its blobs under the policies repository. If token
+// is set, requests must carry it as a bearer token issued by the /token endpoint.
+type fakeRegistry struct {
+	manifest []byte
+	blobs    map[string][]byte
+	token    string
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": f.token})
+		return
+	}
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="http://`+r.Host+`/token",service="registry",scope="repository:policies:pull"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v2/policies/manifests/"):
+		w.Header().Set("Content-Type", ociManifestMediaType)
+		_, _ = w.Write(f.manifest)
+	case strings.HasPrefix(r.URL.Path, "/v2/policies/blobs/"):
+		blob, ok := f.blobs[strings.TrimPrefix(r.URL.Path, "/v2/policies/blobs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(blob)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newFakeRegistry serves a manifest with a layer per blob. A layer with a title is a plain
+// file; otherwise it is a tarball. digest overrides the descriptor digest of a layer.
+func newFakeRegistry(t *testing.T, layers []fakeLayer) *fakeRegistry {
+	t.Helper()
+	registry := &fakeRegistry{blobs: make(map[string][]byte)}
+	var manifest ociManifest
+	for _, layer := range layers {
+		digest := digestOf(layer.blob)
+		registry.blobs[digest] = layer.blob
+		if layer.digest != "" {
+			digest = layer.digest
+			registry.blobs[digest] = layer.blob
+		}
+		descriptor := ociDescriptor{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: digest, Size: int64(len(layer.blob))}
+		if layer.title != "" {
+			descriptor.MediaType = "application/vnd.cncf.openpolicyagent.policy.layer.v1+rego"
+			descriptor.Annotations = map[string]string{ociTitleAnnotation: layer.title}
+		}
+		manifest.Layers = append(manifest.Layers, descriptor)
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.manifest = data
+	return registry
+}
+
+type fakeLayer struct {
+	blob   []byte
+	title  string
+	digest string
+}
+
+func TestOCISourceFetch(t *testing.T) {
+	policies := tarGz(t, map[string]string{"policy/check-1/policy.rego": "package main\n"})
+	tests := []struct {
+		name    string
+		layers  []fakeLayer
+		token   string
+		digest  func(registry *fakeRegistry) string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:   "tarball and file layers",
+			layers: []fakeLayer{{blob: policies}, {blob: []byte(`{"a": 1}`), title: "policy/check-1/data.json"}},
+			want:   map[string]string{"policy.rego": "package main\n", "data.json": `{"a": 1}`},
+		},
+		{
+			name:   "bearer token",
+			layers: []fakeLayer{{blob: policies}},
+			token:  "registry-token",
+			want:   map[string]string{"policy.rego": "package main\n"},
+		},
+		{
+			name:   "expected manifest digest",
+			layers: []fakeLayer{{blob: policies}},
+			digest: func(registry *fakeRegistry) string { return digestOf(registry.manifest) },
+			want:   map[string]string{"policy.rego": "package main\n"},
+		},
+		{
+			name:    "unexpected manifest digest",
+			layers:  []fakeLayer{{blob: policies}},
+			digest:  func(*fakeRegistry) string { return digestOf([]byte("other")) },
+			wantErr: "does not match expected",
+		},
+		{
+			name:    "layer with wrong digest",
+			layers:  []fakeLayer{{blob: policies, digest: digestOf([]byte("tampered"))}},
+			wantErr: "does not match descriptor",
+		},
+		{
+			name:    "tarball entry outside the directory",
+			layers:  []fakeLayer{{blob: tarGz(t, map[string]string{"../../escaped.rego": "package main\n"})}},
+			wantErr: "invalid policy file path",
+		},
+		{
+			name:    "file layer outside the directory",
+			layers:  []fakeLayer{{blob: []byte("package main\n"), title: "../escaped.rego"}},
+			wantErr: "invalid policy file path",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newFakeRegistry(t, tt.layers)
+			registry.token = tt.token
+			server := httptest.NewServer(registry)
+			t.Cleanup(server.Close)
+
+			config := Config{PolicySource: "oci://" + strings.TrimPrefix(server.URL, "http://") + "/policies:v1"}
+			if tt.digest != nil {
+				config.PolicySourceDigest = tt.digest(registry)
+			}
+			source, err := NewPolicySource(config)
+			if err != nil {
+				t.Fatalf("NewPolicySource() error = %v", err)
+			}
+			t.Cleanup(func() { _ = source.Close() })
+
+			dest := filepath.Join(t.TempDir(), "out")
+			err = source.Fetch(context.Background(), "check-1", dest)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Fetch() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if got := readFiles(t, dest); !equalFiles(got, tt.want) {
+				t.Errorf("Fetch() wrote %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOCIReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		ref     string
+		want    ociReference
+		wantURL string
+		wantErr bool
+	}{
+		{
+			ref:     "ghcr.io/org/policy-bundle",
+			want:    ociReference{Registry: "ghcr.io", Repository: "org/policy-bundle", Tag: "latest"},
+			wantURL: "https://ghcr.io/v2/org/policy-bundle",
+		},
+		{
+			ref:     "localhost:5000/policies:dev",
+			want:    ociReference{Registry: "localhost:5000", Repository: "policies", Tag: "dev"},
+			wantURL: "http://localhost:5000/v2/policies",
+		},
+		{
+			ref:     "127.0.0.1:5000/org/policies@" + digest,
+			want:    ociReference{Registry: "127.0.0.1:5000", Repository: "org/policies", Digest: digest},
+			wantURL: "http://127.0.0.1:5000/v2/org/policies",
+		},
+		{ref: "ghcr.io", wantErr: true},
+		{ref: "/policies", wantErr: true},
+		{ref: "ghcr.io/policies@sha512:abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := parseOCIReference(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOCIReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseOCIReference() = %+v, want %+v", got, tt.want)
+			}
+			if url := got.baseURL(); url != tt.wantURL {
+				t.Errorf("baseURL() = %s, want %s", url, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestWriteContained(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "policy/check-1/policy.rego", want: "policy/check-1/policy.rego"},
+		{name: "/policy/check-1/policy.rego", want: "policy/check-1/policy.rego"},
+		{name: "policy/../check-1/policy.rego", want: "check-1/policy.rego"},
+		{name: "../escaped.rego", wantErr: true},
+		{name: "policy/../../escaped.rego", wantErr: true},
+		{name: "/../escaped.rego", wantErr: true},
+		{name: ".", wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := writeContained(dir, tt.name, []byte("content"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeContained(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if files := readFiles(t, filepath.Dir(dir)); len(files) != 0 {
+					t.Errorf("writeContained(%q) wrote %v", tt.name, files)
+				}
+				return
+			}
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(tt.want))); err != nil {
+				t.Errorf("writeContained(%q) did not write %s: %v", tt.name, tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+// OCSF status IDs used by ProofWatch scan activity events.
+const (
+	ocsfStatusSuccess = 1
+	ocsfStatusFailure = 2
+)
+
+// ocsfActivityCompleted is the OCSF ScanActivity activity ID of a completed scan. conftest-exporter
+// logs one per check and file to summarize an evaluation run, including runs without findings.
+const ocsfActivityCompleted = 2
+
+// ocsfEvent holds the fields of an OCSF ScanActivity event logged by ProofWatch that are
+// used as evidence.
+type ocsfEvent struct {
+	// Time is when the event occurred, in Unix milliseconds.
+	Time         int64  `json:"time"`
+	ActivityID   int32  `json:"activity_id"`
+	Status       string `json:"status"`
+	StatusID     int32  `json:"status_id"`
+	StatusDetail string `json:"status_detail"`
+	Message      string `json:"message"`
+	Policy       struct {
+		UID  string `json:"uid"`
+		Name string `json:"name"`
+		Data string `json:"data"`
+	} `json:"policy"`
+	Observables []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"observables"`
+}
+
+// parseOCSFEvent decodes a log body as an OCSF event. It returns false if the body is
+// not a JSON object or has none of the fields used as evidence.
+func parseOCSFEvent(body string) (ocsfEvent, bool) {
+	var event ocsfEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return ocsfEvent{}, false
+	}
+	ok := event.Time != 0 || event.Status != "" || event.Message != "" || event.Policy.UID != "" || len(event.Observables) > 0
+	return event, ok
+}
+
+// summary reports whether the event summarizes a completed scan rather than a single finding.
+func (e ocsfEvent) summary() bool {
+	return e.ActivityID == ocsfActivityCompleted
+}
+
+// time returns when the event occurred, or the zero time if it is not set.
+func (e ocsfEvent) time() time.Time {
+	if e.Time <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(e.Time)
+}
+
+// result maps the OCSF status of the event to a result. It returns false for statuses
+// that do not describe the outcome of an evaluation, such as Unknown or Other.
+func (e ocsfEvent) result() (policy.Result, bool) {
+	switch e.StatusID {
+	case ocsfStatusSuccess:
+		return policy.ResultPass, true
+	case ocsfStatusFailure:
+		return policy.ResultFail, true
+	default:
+		return policy.ResultInvalid, false
+	}
+}
+
+// reason returns the message of the event, falling back to its status detail.
+func (e ocsfEvent) reason() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.StatusDetail
+}
+
+// observable returns the value of the first observable with one of the names, in order
+// of preference.
+func (e ocsfEvent) observable(names ...string) string {
+	for _, name := range names {
+		for _, observable := range e.Observables {
+			if observable.Name == name && observable.Value != "" {
+				return observable.Value
+			}
+		}
+	}
+	return ""
+}
+
+// props returns the policy, status, and message of the event as subject properties.
+func (e ocsfEvent) props() []policy.Property {
+	var props []policy.Property
+	add := func(name, value string) {
+		if value != "" {
+			props = append(props, policy.Property{Name: name, Value: value})
+		}
+	}
+	add("policy_uid", e.Policy.UID)
+	add("policy_name", e.Policy.Name)
+	add("policy_data", e.Policy.Data)
+	add("status", e.Status)
+	add("status_detail", e.StatusDetail)
+	add("message", e.Message)
+	if t := e.time(); !t.IsZero() {
+		add("event_time", t.UTC().Format(time.RFC3339Nano))
+	}
+	return props
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+// ocsfFailure is a failed ScanActivity finding logged by ProofWatch for a Conftest check.
+const ocsfFailure = `{
+	"time": 1767323045000,
+	"activity_id": 1,
+	"status": "Failure",
+	"status_id": 2,
+	"status_detail": "1 failure",
+	"message": "branch main is not protected",
+	"policy": {"uid": "check-1", "name": "Branch protection", "data": "{\"enabled\": true}"},
+	"observables": [
+		{"name": "file.name", "value": "branches.json"},
+		{"name": "resource.name", "value": "org/repo"}
+	]
+}`
+
+func TestParseOCSFEvent(t *testing.T) {
+	eventTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name        string
+		body        string
+		wantOK      bool
+		wantResult  policy.Result
+		wantResOK   bool
+		wantReason  string
+		wantTime    time.Time
+		wantSummary bool
+		wantSubject string
+	}{
+		{
+			name:        "finding",
+			body:        ocsfFailure,
+			wantOK:      true,
+			wantResult:  policy.ResultFail,
+			wantResOK:   true,
+			wantReason:  "branch main is not protected",
+			wantTime:    eventTime,
+			wantSubject: "org/repo",
+		},
+		{
+			name:        "completed scan",
+			body:        `{"time": 1767323045000, "activity_id": 2, "status_id": 1, "status_detail": "No violations found", "observables": [{"name": "file.name", "value": "branches.json"}]}`,
+			wantOK:      true,
+			wantResult:  policy.ResultPass,
+			wantResOK:   true,
+			wantReason:  "No violations found",
+			wantTime:    eventTime,
+			wantSummary: true,
+			wantSubject: "branches.json",
+		},
+		{
+			name:       "unknown status",
+			body:       `{"status": "Unknown", "status_id": 0, "message": "not evaluated"}`,
+			wantOK:     true,
+			wantResult: policy.ResultInvalid,
+			wantReason: "not evaluated",
+		},
+		{
+			name:        "observables only",
+			body:        `{"observables": [{"name": "resource.uid", "value": ""}, {"name": "resource.uid", "value": "bucket-1"}]}`,
+			wantOK:      true,
+			wantResult:  policy.ResultInvalid,
+			wantSubject: "bucket-1",
+		},
+		{name: "unrelated JSON object", body: `{"level": "info", "msg": "started"}`},
+		{name: "JSON array", body: `[{"status": "Failure"}]`},
+		{name: "plain text", body: "check-1 failed"},
+		{name: "mistyped field", body: `{"time": "yesterday", "status": "Failure"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, ok := parseOCSFEvent(tt.body)
+			if ok != tt.wantOK {
+				t.Fatalf("parseOCSFEvent() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if result, ok := event.result(); result != tt.wantResult || ok != tt.wantResOK {
+				t.Errorf("result() = %s, %v, want %s, %v", result, ok, tt.wantResult, tt.wantResOK)
+			}
+			if got := event.reason(); got != tt.wantReason {
+				t.Errorf("reason() = %q, want %q", got, tt.wantReason)
+			}
+			if got := event.time(); !got.Equal(tt.wantTime) {
+				t.Errorf("time() = %v, want %v", got, tt.wantTime)
+			}
+			if got := event.summary(); got != tt.wantSummary {
+				t.Errorf("summary() = %v, want %v", got, tt.wantSummary)
+			}
+			if got := event.observable(resourceObservables...); got != tt.wantSubject {
+				t.Errorf("observable() = %q, want %q", got, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestOCSFEventProps(t *testing.T) {
+	event, ok := parseOCSFEvent(ocsfFailure)
+	if !ok {
+		t.Fatal("parseOCSFEvent() ok = false, want true")
+	}
+	want := []policy.Property{
+		{Name: "policy_uid", Value: "check-1"},
+		{Name: "policy_name", Value: "Branch protection"},
+		{Name: "policy_data", Value: `{"enabled": true}`},
+		{Name: "status", Value: "Failure"},
+		{Name: "status_detail", Value: "1 failure"},
+		{Name: "message", Value: "branch main is not protected"},
+		{Name: "event_time", Value: "2026-01-02T03:04:05Z"},
+	}
+	if got := event.props(); !reflect.DeepEqual(got, want) {
+		t.Errorf("props() = %v, want %v", got, want)
+	}
+	if got := (ocsfEvent{StatusID: ocsfStatusSuccess}).props(); got != nil {
+		t.Errorf("props() without fields = %v, want none", got)
+	}
+}
+
+func TestLokiRecordOCSF(t *testing.T) {
+	source, err := newLokiSource(Config{LokiURL: "http://localhost:3100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entryTime := time.Date(2026, 1, 2, 4, 0, 0, 0, time.UTC)
+	entry := func(message string, labels map[string]string) LokiLogEntry {
+		if labels == nil {
+			labels = map[string]string{"policy_id": "check-1"}
+		}
+		return LokiLogEntry{Timestamp: "1767326400000000000", Message: message, Labels: labels}
+	}
+
+	tests := []struct {
+		name        string
+		entry       LokiLogEntry
+		wantResult  policy.Result
+		wantReason  string
+		wantTime    time.Time
+		wantSubject string
+		wantSummary bool
+		wantMessage string
+	}{
+		{
+			name:        "OCSF body",
+			entry:       entry(ocsfFailure, nil),
+			wantResult:  policy.ResultFail,
+			wantReason:  "branch main is not protected",
+			wantTime:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			wantSubject: "org/repo",
+			wantMessage: "branch main is not protected",
+		},
+		{
+			name: "labels take precedence",
+			entry: entry(ocsfFailure, map[string]string{
+				"policy_id":            "check-1",
+				"compliance_status":    "Pass",
+				"policy_status_detail": "excepted",
+				"repository":           "org/other",
+			}),
+			wantResult:  policy.ResultPass,
+			wantReason:  "excepted",
+			wantTime:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			wantSubject: "org/other",
+			wantMessage: "branch main is not protected",
+		},
+		{
+			name:        "completed scan",
+			entry:       entry(`{"time": 1767323045000, "activity_id": 2, "status_id": 1, "status_detail": "No violations found"}`, nil),
+			wantResult:  policy.ResultPass,
+			wantReason:  "No violations found",
+			wantTime:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			wantSubject: unknownResource,
+			wantSummary: true,
+		},
+		{
+			name:        "OCSF body without an outcome",
+			entry:       entry(`{"status_id": 99, "message": "other"}`, nil),
+			wantResult:  policy.ResultError,
+			wantReason:  "other",
+			wantTime:    entryTime,
+			wantSubject: unknownResource,
+			wantMessage: "other",
+		},
+		{
+			name:        "plain text body",
+			entry:       entry("check-1 failed", nil),
+			wantResult:  policy.ResultError,
+			wantReason:  "Evidence found in Loki logs",
+			wantTime:    entryTime,
+			wantSubject: unknownResource,
+			wantMessage: "check-1 failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := source.record("check-1", `{policy_id="check-1"}`, tt.entry)
+			if record.Result != tt.wantResult || record.Reason != tt.wantReason {
+				t.Errorf("record() = %s (%s), want %s (%s)", record.Result, record.Reason, tt.wantResult, tt.wantReason)
+			}
+			if !record.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("record() timestamp = %v, want %v", record.Timestamp, tt.wantTime)
+			}
+			if record.SubjectID != tt.wantSubject || record.Summary != tt.wantSummary {
+				t.Errorf("record() subject = %s (summary %v), want %s (summary %v)", record.SubjectID, record.Summary, tt.wantSubject, tt.wantSummary)
+			}
+			if got := propValue(record.Props, "message"); got != tt.wantMessage {
+				t.Errorf("record() message prop = %q, want %q", got, tt.wantMessage)
+			}
+		})
+	}
+}
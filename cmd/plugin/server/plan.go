@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	oscalTypes "github.com/defenseunicorns/go-oscal/src/types/oscal-1-1-3"
+	"github.com/jpower432/opensource-securitycon-2025-oscal-in-action/internal/signing"
+)
+
+// verifyPlan checks the detached signature of the configured assessment plan so
+// tampering is detected before results are aggregated.
+func verifyPlan(config Config) error {
+	key, err := signing.LoadPublicKey(config.VerifyPlan)
+	if err != nil {
+		return err
+	}
+	signaturePath := config.PlanSignature
+	if signaturePath == "" {
+		signaturePath = signing.SignatureFile(config.AssessmentPlan)
+	}
+	return signing.VerifyFile(config.AssessmentPlan, signaturePath, key)
+}
+
+// loadAssessmentPlan reads the OSCAL Assessment Plan at path.
+func loadAssessmentPlan(path string) (*oscalTypes.AssessmentPlan, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	var models oscalTypes.OscalModels
+	if err := json.Unmarshal(data, &models); err != nil {
+		return nil, fmt.Errorf("error decoding assessment plan %s: %w", path, err)
+	}
+	if models.AssessmentPlan == nil {
+		return nil, errors.New("assessment-plan does not contain an OSCAL Assessment Plan")
+	}
+	return models.AssessmentPlan, nil
+}
+
+// assessmentPlanUUID returns the UUID of the OSCAL Assessment Plan at path.
+func assessmentPlanUUID(path string) (string, error) {
+	plan, err := loadAssessmentPlan(path)
+	if err != nil {
+		return "", err
+	}
+	return plan.UUID, nil
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/cover"
+	"github.com/open-policy-agent/opa/v1/loader"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/open-policy-agent/opa/v1/storage/inmem"
+	"github.com/open-policy-agent/opa/v1/tester"
+	"github.com/open-policy-agent/opa/v1/types"
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+// parseConfigFileBuiltin matches the Conftest built-in used by check tests to load fixtures.
+const parseConfigFileBuiltin = "parse_config_file"
+
+// TestPolicySet runs the Rego tests that ship with each check in the policy output
+// directory. An error is returned if any test fails or errors, or if the coverage of
+// a check's policy is below minCoverage. A minCoverage of zero disables the coverage check.
+func (c *Composer) TestPolicySet(ctx context.Context, pl policy.Policy, minCoverage float64) error {
+	seen := make(map[string]struct{})
+	var errs []error
+	for _, rule := range pl {
+		for _, check := range rule.Checks {
+			if _, ok := seen[check.ID]; ok {
+				continue
+			}
+			seen[check.ID] = struct{}{}
+
+			if err := testCheck(ctx, filepath.Join(c.policyOutput, "policy", check.ID), minCoverage); err != nil {
+				errs = append(errs, fmt.Errorf("check %s: %w", check.ID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// testCheck compiles the Rego files in a check directory, each with the Rego version
+// it is written in, and runs its tests. The data documents written by GenerateData are
+// loaded at data.<check ID>, where Conftest finds them.
+func testCheck(ctx context.Context, checkDir string, minCoverage float64) error {
+	loaded, err := loadRegoModules(checkDir, true)
+	if err != nil {
+		return err
+	}
+	modules := make(map[string]*ast.Module, len(loaded))
+	var hasTests bool
+	for _, module := range loaded {
+		modules[module.Path] = module.Parsed
+		if isTestFile(module.Path) {
+			hasTests = true
+		}
+	}
+	if !hasTests {
+		logger.Warn("No Rego tests found for check", "path", checkDir)
+		return nil
+	}
+
+	documents, err := loader.NewFileLoader().Filtered([]string{checkDir}, func(abspath string, info fs.FileInfo, _ int) bool {
+		return !info.IsDir() && !isDataFile(info.Name())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load check data: %w", err)
+	}
+	store := inmem.NewFromObject(map[string]any{filepath.Base(checkDir): documents.Documents})
+
+	coverage := cover.New()
+	runner := tester.NewRunner().
+		SetStore(store).
+		SetDefaultRegoVersion(regoVersion).
+		SetCoverageQueryTracer(coverage).
+		RaiseBuiltinErrors(true).
+		AddCustomBuiltins([]*tester.Builtin{parseConfigFile(checkDir)})
+
+	ch, err := runner.Run(ctx, modules)
+	if err != nil {
+		return fmt.Errorf("failed to compile policy: %w", err)
+	}
+
+	var failed []string
+	var total int
+	for result := range ch {
+		if result.Skip {
+			continue
+		}
+		total++
+		switch {
+		case result.Error != nil:
+			failed = append(failed, fmt.Sprintf("%s.%s: %v", result.Package, result.Name, result.Error))
+		case result.Fail:
+			failed = append(failed, fmt.Sprintf("%s.%s", result.Package, result.Name))
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("%d of %d tests failed: %s", len(failed), total, strings.Join(failed, "; "))
+	}
+
+	policyModules := make(map[string]*ast.Module)
+	for path, module := range modules {
+		if !isTestFile(path) {
+			policyModules[path] = module
+		}
+	}
+	report := coverage.Report(policyModules)
+	logger.Info("Rego tests passed", "path", checkDir, "tests", total, "coverage", fmt.Sprintf("%.2f%%", report.Coverage))
+	if minCoverage > 0 && report.Coverage < minCoverage {
+		return fmt.Errorf("coverage %.2f%% is below the minimum of %.2f%%", report.Coverage, minCoverage)
+	}
+	return nil
+}
+
+func isTestFile(path string) bool {
+	return strings.HasSuffix(path, "_test.rego")
+}
+
+// parseConfigFile returns a parse_config_file built-in for the tests of a check.
+// Relative paths are resolved against the directory of the calling Rego file and then
+// each parent directory up to the check directory, so fixtures stored beside the
+// policy directory can be found.
+func parseConfigFile(checkDir string) *tester.Builtin {
+	decl := &rego.Function{
+		Name: parseConfigFileBuiltin,
+		Decl: types.NewFunction(types.Args(types.S), types.A),
+	}
+	return &tester.Builtin{
+		Decl: &ast.Builtin{Name: decl.Name, Decl: decl.Decl},
+		Func: rego.Function1(decl, func(bctx rego.BuiltinContext, op *ast.Term) (*ast.Term, error) {
+			name, ok := op.Value.(ast.String)
+			if !ok {
+				return nil, fmt.Errorf("%s: path must be a string", parseConfigFileBuiltin)
+			}
+			var from string
+			if bctx.Location != nil {
+				from = filepath.Dir(bctx.Location.File)
+			}
+			path, err := resolveFixture(checkDir, from, string(name))
+			if err != nil {
+				return nil, err
+			}
+			value, err := readConfigFile(path)
+			if err != nil {
+				return nil, err
+			}
+			return ast.NewTerm(value), nil
+		}),
+	}
+}
+
+func resolveFixture(checkDir, from, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return name, nil
+	}
+	root, err := filepath.Abs(checkDir)
+	if err != nil {
+		return "", err
+	}
+	dir := root
+	if from != "" {
+		if dir, err = filepath.Abs(from); err != nil {
+			return "", err
+		}
+	}
+	for {
+		candidate := filepath.Join(dir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+		if dir == root || !strings.HasPrefix(dir, root) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return "", fmt.Errorf("%s: file %q not found in %s", parseConfigFileBuiltin, name, checkDir)
+}
+
+// readConfigFile parses a JSON or YAML file into a Rego value.
+func readConfigFile(path string) (ast.Value, error) {
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &doc)
+	default:
+		err = json.Unmarshal(content, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to parse %s: %w", parseConfigFileBuiltin, path, err)
+	}
+	return ast.InterfaceToValue(doc)
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// thresholdPolicy denies inputs with fewer reviews than the check's required_reviews parameter.
+const thresholdPolicy = `package main
+
+import rego.v1
+
+deny contains "not enough reviews" if {
+	input.reviews < data["check-1"].parameters.required_reviews
+}
+`
+
+func TestTestCheck(t *testing.T) {
+	data := `{"parameters": {"required_reviews": 2}, "rules": {}}`
+	tests := []struct {
+		name        string
+		files       map[string]string
+		minCoverage float64
+		wantErr     string
+	}{
+		{
+			name: "tests read generated data",
+			files: map[string]string{
+				"policy/policy.rego": thresholdPolicy,
+				"policy/policy_test.rego": `package main_test
+
+import rego.v1
+
+import data.main
+
+test_deny if {
+	main.deny["not enough reviews"] with input as {"reviews": 1}
+}
+
+test_allow if {
+	count(main.deny) == 0 with input as {"reviews": 2}
+}
+`,
+				"data.json": data,
+			},
+			minCoverage: 100,
+		},
+		{
+			name: "tests override data",
+			files: map[string]string{
+				"policy/policy.rego": thresholdPolicy,
+				"policy/policy_test.rego": `package main_test
+
+import rego.v1
+
+import data.main
+
+test_override if {
+	count(main.deny) == 0 with input as {"reviews": 1} with data["check-1"].parameters.required_reviews as 1
+}
+`,
+				"data.json": data,
+			},
+		},
+		{
+			name: "failing test",
+			files: map[string]string{
+				"policy/policy.rego": thresholdPolicy,
+				"policy/policy_test.rego": `package main_test
+
+import rego.v1
+
+import data.main
+
+test_allow if {
+	count(main.deny) == 0 with input as {"reviews": 1}
+}
+`,
+				"data.json": data,
+			},
+			wantErr: "1 of 1 tests failed: data.main_test.test_allow",
+		},
+		{
+			name: "fixtures beside the policy directory",
+			files: map[string]string{
+				"policy/policy.rego": thresholdPolicy,
+				"policy/policy_test.rego": `package main_test
+
+import rego.v1
+
+import data.main
+
+test_fixtures if {
+	main.deny["not enough reviews"] with input as parse_config_file("fixtures/few.yaml")
+	count(main.deny) == 0 with input as parse_config_file("many.json")
+}
+`,
+				"fixtures/few.yaml": "reviews: 1\n",
+				"policy/many.json":  `{"reviews": 3}`,
+				"data.json":         data,
+			},
+		},
+		{
+			name: "missing fixture",
+			files: map[string]string{
+				"policy/policy.rego": thresholdPolicy,
+				"policy/policy_test.rego": `package main_test
+
+import rego.v1
+
+test_fixture if {
+	parse_config_file("missing.yaml")
+}
+`,
+			},
+			wantErr: `file "missing.yaml" not found`,
+		},
+		{
+			name: "coverage below minimum",
+			files: map[string]string{
+				"policy/policy.rego": thresholdPolicy + `
+warn contains "untested" if input.untested
+`,
+				"policy/policy_test.rego": `package main_test
+
+import rego.v1
+
+import data.main
+
+test_allow if {
+	count(main.deny) == 0 with input as {"reviews": 2}
+}
+`,
+				"data.json": data,
+			},
+			minCoverage: 100,
+			wantErr:     "below the minimum of 100.00%",
+		},
+		{
+			name:  "no tests",
+			files: map[string]string{"policy/policy.rego": thresholdPolicy},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkDir := filepath.Join(t.TempDir(), "check-1")
+			writeFiles(t, checkDir, tt.files)
+			err := testCheck(context.Background(), checkDir, tt.minCoverage)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("testCheck() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("testCheck() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveFixture(t *testing.T) {
+	root := t.TempDir()
+	checkDir := filepath.Join(root, "check-1")
+	writeFiles(t, root, map[string]string{
+		"check-1/input.json":           "{}",
+		"check-1/policy/input.json":    "{}",
+		"check-1/policy/nested/a.yaml": "a: 1",
+		"check-1/fixtures/b.yaml":      "b: 1",
+		"outside.json":                 "{}",
+	})
+	policyDir := filepath.Join(checkDir, "policy")
+	nestedDir := filepath.Join(policyDir, "nested")
+
+	tests := []struct {
+		name    string
+		from    string
+		file    string
+		want    string
+		wantErr bool
+	}{
+		{name: "beside the calling file", from: nestedDir, file: "a.yaml", want: filepath.Join(nestedDir, "a.yaml")},
+		{name: "nearest parent wins", from: nestedDir, file: "input.json", want: filepath.Join(policyDir, "input.json")},
+		{name: "check directory", from: nestedDir, file: "fixtures/b.yaml", want: filepath.Join(checkDir, "fixtures", "b.yaml")},
+		{name: "no calling file", file: "input.json", want: filepath.Join(checkDir, "input.json")},
+		{name: "absolute path", from: nestedDir, file: filepath.Join(root, "outside.json"), want: filepath.Join(root, "outside.json")},
+		{name: "outside the check directory", from: nestedDir, file: "outside.json", wantErr: true},
+		{name: "calling file outside the check directory", from: root, file: "a.yaml", wantErr: true},
+		{name: "missing", from: nestedDir, file: "missing.yaml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveFixture(checkDir, tt.from, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveFixture() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveFixture() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"input.yaml":   "reviews: 2\nbranches: [main]\n",
+		"input.yml":    "reviews: 2\nbranches: [main]\n",
+		"input.json":   `{"reviews": 2, "branches": ["main"]}`,
+		"input.tfplan": `{"reviews": 2, "branches": ["main"]}`,
+		"invalid.json": `{"reviews":`,
+		"invalid.yaml": "reviews: [",
+	})
+	want := `{"branches": ["main"], "reviews": 2}`
+	tests := []struct {
+		file    string
+		wantErr bool
+	}{
+		{file: "input.yaml"},
+		{file: "input.yml"},
+		{file: "input.json"},
+		{file: "input.tfplan"},
+		{file: "invalid.json", wantErr: true},
+		{file: "invalid.yaml", wantErr: true},
+		{file: "missing.json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := readConfigFile(filepath.Join(dir, tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != want {
+				t.Errorf("readConfigFile() = %s, want %s", got, want)
+			}
+		})
+	}
+}
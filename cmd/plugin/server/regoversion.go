@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/format"
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+const (
+	// regoVersionAnnotation is the custom METADATA key used to declare the Rego version of a file.
+	regoVersionAnnotation = "rego_version"
+	// upgradeReportFile is written to the policy output directory when checks are upgraded.
+	upgradeReportFile = "rego-upgrade-report.json"
+)
+
+var regoV1ImportPattern = regexp.MustCompile(`(?m)^\s*import\s+rego\.v1\s*$`)
+
+// regoModule is a Rego file parsed with the Rego version it is written in.
+type regoModule struct {
+	Path    string
+	Raw     []byte
+	Version ast.RegoVersion
+	Parsed  *ast.Module
+}
+
+// parseRegoModule detects the Rego version of a file and parses it. A rego_version
+// entry in the custom METADATA of the package takes precedence; otherwise the file is
+// parsed as v1 and then as v0. Files that import rego.v1 but are written in v0 syntax
+// are parsed as v0 without the import.
+func parseRegoModule(path string, raw []byte) (regoModule, error) {
+	module := regoModule{Path: path, Raw: raw}
+	v1, v1Err := parseWithVersion(path, raw, ast.RegoV1)
+	if v1Err == nil {
+		module.Version, module.Parsed = ast.RegoV1, v1
+	} else if v0, err := parseWithVersion(path, raw, ast.RegoV0); err == nil {
+		module.Version, module.Parsed = ast.RegoV0, v0
+	} else if stripped := regoV1ImportPattern.ReplaceAll(raw, nil); !bytes.Equal(stripped, raw) {
+		v0, err := parseWithVersion(path, stripped, ast.RegoV0)
+		if err != nil {
+			return module, v1Err
+		}
+		logger.Warn("Rego file imports rego.v1 but is written in v0 syntax, ignoring the import", "path", path)
+		module.Raw, module.Version, module.Parsed = stripped, ast.RegoV0, v0
+	} else {
+		return module, v1Err
+	}
+
+	declared, err := declaredRegoVersion(module.Parsed)
+	if err != nil {
+		return module, fmt.Errorf("%s: %w", path, err)
+	}
+	if declared != ast.RegoUndefined && declared != module.Version {
+		parsed, err := parseWithVersion(path, module.Raw, declared)
+		if err != nil {
+			return module, err
+		}
+		module.Version, module.Parsed = declared, parsed
+	}
+	return module, nil
+}
+
+func parseWithVersion(path string, raw []byte, version ast.RegoVersion) (*ast.Module, error) {
+	return ast.ParseModuleWithOpts(path, string(raw), ast.ParserOptions{RegoVersion: version, ProcessAnnotation: true})
+}
+
+// declaredRegoVersion returns the Rego version declared in the custom METADATA of the package.
+func declaredRegoVersion(module *ast.Module) (ast.RegoVersion, error) {
+	for _, annotation := range module.Annotations {
+		if annotation.Scope != "package" {
+			continue
+		}
+		value, ok := annotation.Custom[regoVersionAnnotation]
+		if !ok {
+			continue
+		}
+		switch fmt.Sprint(value) {
+		case "v0", "0":
+			return ast.RegoV0, nil
+		case "v1", "1":
+			return ast.RegoV1, nil
+		default:
+			return ast.RegoUndefined, fmt.Errorf("invalid %s annotation %q: must be v0 or v1", regoVersionAnnotation, value)
+		}
+	}
+	return ast.RegoUndefined, nil
+}
+
+// loadRegoModules parses every Rego file under dir. Test files are included only if tests is true.
+func loadRegoModules(dir string, tests bool) ([]regoModule, error) {
+	paths, err := regoFiles(dir, tests)
+	if err != nil {
+		return nil, err
+	}
+	modules := make([]regoModule, 0, len(paths))
+	for _, path := range paths {
+		module, err := readRegoModule(path)
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, module)
+	}
+	return modules, nil
+}
+
+// regoFiles returns the paths of the Rego files under dir.
+func regoFiles(dir string, tests bool) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && filepath.Ext(path) == ".rego" && (tests || !isTestFile(path)) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func readRegoModule(path string) (regoModule, error) {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return regoModule{}, err
+	}
+	return parseRegoModule(path, raw)
+}
+
+// regoUpgrade records the changes made when a file was rewritten from v0 to v1.
+type regoUpgrade struct {
+	Check   string   `json:"check"`
+	File    string   `json:"file"`
+	Changes []string `json:"changes,omitempty"`
+	// Error is set when a test file could not be parsed and was left unchanged.
+	Error string `json:"error,omitempty"`
+}
+
+// UpgradePolicySet rewrites the v0 Rego files of every check in the policy output
+// directory to v1 syntax and writes a report of the changed lines to rego-upgrade-report.json.
+// Test files that cannot be parsed are left unchanged and reported; policy files that
+// cannot be parsed fail the upgrade.
+func (c *Composer) UpgradePolicySet(pl policy.Policy) ([]regoUpgrade, error) {
+	seen := make(map[string]struct{})
+	upgrades := []regoUpgrade{}
+	for _, rule := range pl {
+		for _, check := range rule.Checks {
+			if _, ok := seen[check.ID]; ok {
+				continue
+			}
+			seen[check.ID] = struct{}{}
+
+			checkDir := filepath.Join(c.policyOutput, "policy", check.ID)
+			paths, err := regoFiles(checkDir, true)
+			if err != nil {
+				return nil, fmt.Errorf("check %s: %w", check.ID, err)
+			}
+			for _, path := range paths {
+				rel, err := filepath.Rel(checkDir, path)
+				if err != nil {
+					return nil, err
+				}
+				module, err := readRegoModule(path)
+				switch {
+				case err != nil && isTestFile(path):
+					logger.Warn("Skipping Rego test file that could not be parsed", "check_id", check.ID, "file", rel, "error", err)
+					upgrades = append(upgrades, regoUpgrade{Check: check.ID, File: filepath.ToSlash(rel), Error: err.Error()})
+					continue
+				case err != nil:
+					return nil, fmt.Errorf("check %s: %w", check.ID, err)
+				case module.Version != ast.RegoV0:
+					continue
+				}
+				upgraded, err := format.SourceWithOpts(module.Path, module.Raw, format.Opts{
+					RegoVersion:   ast.RegoV1,
+					ParserOptions: &ast.ParserOptions{RegoVersion: ast.RegoV0},
+				})
+				if err != nil {
+					return nil, fmt.Errorf("failed to upgrade %s: %w", module.Path, err)
+				}
+				if err := os.WriteFile(module.Path, upgraded, 0600); err != nil {
+					return nil, err
+				}
+				upgrades = append(upgrades, regoUpgrade{
+					Check:   check.ID,
+					File:    filepath.ToSlash(rel),
+					Changes: lineChanges(string(module.Raw), string(upgraded)),
+				})
+				logger.Info("Upgraded Rego file to v1", "check_id", check.ID, "file", rel)
+			}
+		}
+	}
+
+	sort.SliceStable(upgrades, func(i, j int) bool {
+		if upgrades[i].Check != upgrades[j].Check {
+			return upgrades[i].Check < upgrades[j].Check
+		}
+		return upgrades[i].File < upgrades[j].File
+	})
+	report, err := json.MarshalIndent(upgrades, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(c.policyOutput, upgradeReportFile), report, 0600); err != nil {
+		return nil, err
+	}
+	return upgrades, nil
+}
+
+// lineChanges returns the removed and added lines between two versions of a file,
+// prefixed with "-" and "+" in the order they appear.
+func lineChanges(before, after string) []string {
+	a := strings.Split(strings.TrimRight(before, "\n"), "\n")
+	b := strings.Split(strings.TrimRight(after, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	changes := []string{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			changes = append(changes, "+"+b[j])
+			j++
+		default:
+			changes = append(changes, "-"+a[i])
+			i++
+		}
+	}
+	return changes
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+)
+
+const (
+	// v0Policy is only valid Rego v0.
+	v0Policy = `package main
+
+deny[msg] {
+	not input.protected
+	msg := "unprotected"
+}
+`
+	// v1Policy is only valid Rego v1.
+	v1Policy = `package main
+
+deny contains "unprotected" if not input.protected
+`
+)
+
+func TestParseRegoModule(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		wantVersion ast.RegoVersion
+		wantRaw     string
+		wantErr     string
+	}{
+		{
+			name:        "v1",
+			raw:         v1Policy,
+			wantVersion: ast.RegoV1,
+		},
+		{
+			name:        "v0",
+			raw:         v0Policy,
+			wantVersion: ast.RegoV0,
+		},
+		{
+			name:        "v0 importing rego.v1",
+			raw:         strings.Replace(v0Policy, "package main\n", "package main\n\nimport rego.v1\n", 1),
+			wantVersion: ast.RegoV0,
+			wantRaw:     v0Policy,
+		},
+		{
+			name:        "valid in both versions",
+			raw:         "package main\n\nallow := true\n",
+			wantVersion: ast.RegoV1,
+		},
+		{
+			name:        "declared v0",
+			raw:         "# METADATA\n# custom:\n#   rego_version: v0\npackage main\n\nallow := true\n",
+			wantVersion: ast.RegoV0,
+		},
+		{
+			name:    "declared v1 but written in v0",
+			raw:     "# METADATA\n# custom:\n#   rego_version: v1\n" + v0Policy,
+			wantErr: "rego_parse_error",
+		},
+		{
+			name:    "invalid declaration",
+			raw:     "# METADATA\n# custom:\n#   rego_version: v2\npackage main\n",
+			wantErr: `invalid rego_version annotation "v2"`,
+		},
+		{
+			name:    "invalid in both versions",
+			raw:     "package main\n\ndeny {\n",
+			wantErr: "rego_parse_error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			module, err := parseRegoModule("policy.rego", []byte(tt.raw))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseRegoModule() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRegoModule() error = %v", err)
+			}
+			if module.Version != tt.wantVersion {
+				t.Errorf("parseRegoModule() version = %v, want %v", module.Version, tt.wantVersion)
+			}
+			wantRaw := tt.wantRaw
+			if wantRaw == "" {
+				wantRaw = tt.raw
+			}
+			if string(module.Raw) != wantRaw {
+				t.Errorf("parseRegoModule() raw = %q, want %q", module.Raw, wantRaw)
+			}
+			if module.Parsed == nil || module.Parsed.Package.Path.String() != "data.main" {
+				t.Errorf("parseRegoModule() parsed %v, want package main", module.Parsed)
+			}
+		})
+	}
+}
+
+func TestUpgradePolicySet(t *testing.T) {
+	output := t.TempDir()
+	writeFiles(t, output, map[string]string{
+		"policy/check-1/policy.rego":      v0Policy,
+		"policy/check-1/policy_test.rego": "package main_test\n\ntest_deny {\n",
+		"policy/check-2/policy.rego":      v1Policy,
+	})
+	composer := NewComposer("", output)
+
+	upgrades, err := composer.UpgradePolicySet(testPolicy("check-1", "check-2"))
+	if err != nil {
+		t.Fatalf("UpgradePolicySet() error = %v", err)
+	}
+	if len(upgrades) != 2 || upgrades[0].File != "policy.rego" || upgrades[1].File != "policy_test.rego" {
+		t.Fatalf("UpgradePolicySet() = %+v, want the check-1 policy and test files", upgrades)
+	}
+	if upgrades[0].Check != "check-1" || upgrades[0].Error != "" || len(upgrades[0].Changes) == 0 {
+		t.Errorf("UpgradePolicySet() policy.rego = %+v, want check-1 changes", upgrades[0])
+	}
+	if upgrades[1].Error == "" || upgrades[1].Changes != nil {
+		t.Errorf("UpgradePolicySet() policy_test.rego = %+v, want a parse error and no changes", upgrades[1])
+	}
+
+	files := readFiles(t, output)
+	module, err := parseRegoModule("policy.rego", []byte(files["policy/check-1/policy.rego"]))
+	if err != nil || module.Version != ast.RegoV1 || !strings.Contains(files["policy/check-1/policy.rego"], "deny contains msg if {") {
+		t.Errorf("upgraded policy.rego = %s (version %v, error %v), want v1", files["policy/check-1/policy.rego"], module.Version, err)
+	}
+	if files["policy/check-1/policy_test.rego"] != "package main_test\n\ntest_deny {\n" {
+		t.Errorf("UpgradePolicySet() rewrote the unparsable test file")
+	}
+	if files["policy/check-2/policy.rego"] != v1Policy {
+		t.Errorf("UpgradePolicySet() rewrote the v1 policy of check-2")
+	}
+
+	var report []regoUpgrade
+	if err := json.Unmarshal([]byte(files[upgradeReportFile]), &report); err != nil {
+		t.Fatalf("%s: %v", upgradeReportFile, err)
+	}
+	if !reflect.DeepEqual(report, upgrades) {
+		t.Errorf("%s = %+v, want %+v", upgradeReportFile, report, upgrades)
+	}
+
+	t.Run("unparsable policy", func(t *testing.T) {
+		output := t.TempDir()
+		writeFiles(t, output, map[string]string{"policy/check-1/policy.rego": "package main\n\ndeny {\n"})
+		if _, err := NewComposer("", output).UpgradePolicySet(testPolicy("check-1")); err == nil {
+			t.Error("UpgradePolicySet() succeeded, want an error")
+		}
+		if _, ok := readFiles(t, output)[upgradeReportFile]; ok {
+			t.Errorf("UpgradePolicySet() wrote %s despite the error", upgradeReportFile)
+		}
+	})
+}
+
+func TestLineChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []string
+	}{
+		{name: "unchanged", before: "a\nb\n", after: "a\nb", want: []string{}},
+		{name: "added", before: "a\nc\n", after: "a\nb\nc\n", want: []string{"+b"}},
+		{name: "removed", before: "a\nb\nc\n", after: "a\nc\n", want: []string{"-b"}},
+		{name: "replaced", before: "a\nb\nc\n", after: "a\nB\nc\n", want: []string{"+B", "-b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineChanges(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lineChanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
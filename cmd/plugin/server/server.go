@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-viper/mapstructure/v2"
@@ -24,8 +27,9 @@ func Logger() hclog.Logger {
 }
 
 type Plugin struct {
-	config     *Config
-	lokiClient *LokiClient
+	config   *Config
+	evidence EvidenceSource
+	subjects *subjectMapper
 }
 
 func NewPlugin() *Plugin {
@@ -34,7 +38,7 @@ func NewPlugin() *Plugin {
 	}
 }
 
-func (p *Plugin) Configure(_ context.Context, m map[string]string) error {
+func (p *Plugin) Configure(ctx context.Context, m map[string]string) error {
 	if err := mapstructure.Decode(m, &p.config); err != nil {
 		return errors.New("error decoding configuration")
 	}
@@ -42,34 +46,72 @@ func (p *Plugin) Configure(_ context.Context, m map[string]string) error {
 	// Load configuration from environment variables
 	p.config.LoadFromEnv()
 
-	// Initialize Loki client - prioritize Grafana Cloud, fall back to local Loki
-	if p.config.GrafanaCloudEndpoint != "" {
-		// Use Grafana Cloud endpoint with authentication
-		p.lokiClient = NewLokiClientWithAuth(
-			p.config.GrafanaCloudEndpoint,
-			p.config.GrafanaCloudInstanceID,
-			p.config.GrafanaCloudAPIKey,
-		)
-		logger.Info("Initialized Loki client with Grafana Cloud endpoint")
-	} else if p.config.LokiURL != "" {
-		p.lokiClient = NewLokiClient(p.config.LokiURL)
-		logger.Info("Initialized Loki client with local endpoint")
-	}
-
-	return p.config.Validate()
+	if err := p.config.Validate(); err != nil {
+		return err
+	}
+
+	evidence, err := NewEvidenceSource(*p.config)
+	if err != nil {
+		return err
+	}
+	p.evidence = evidence
+
+	subjects, err := newSubjectMapper(*p.config)
+	if err != nil {
+		return err
+	}
+	p.subjects = subjects
+
+	if p.config.HealthCheckEnabled() {
+		if err := checkEvidenceSource(ctx, evidence); err != nil {
+			return err
+		}
+	}
+
+	if p.config.VerifyPlan != "" {
+		if err := verifyPlan(*p.config); err != nil {
+			return fmt.Errorf("assessment plan verification failed: %w", err)
+		}
+		logger.Info("Verified assessment plan signature")
+	}
+	return nil
 }
 
 func (p *Plugin) Generate(ctx context.Context, pl policy.Policy) error {
 	composer := NewComposer(p.config.PolicyTemplates, p.config.PolicyOutput)
-	if err := composer.GeneratePolicySet(pl, *p.config); err != nil {
+	if err := composer.GeneratePolicySet(ctx, pl, *p.config); err != nil {
 		return fmt.Errorf("error generating policies: %w", err)
 	}
 
+	if p.config.RegoUpgradeEnabled() {
+		upgrades, err := composer.UpgradePolicySet(pl)
+		if err != nil {
+			return fmt.Errorf("error upgrading policies: %w", err)
+		}
+		logger.Info("Upgraded Rego v0 policies", "files", len(upgrades), "report", filepath.Join(p.config.PolicyOutput, upgradeReportFile))
+	}
+
+	if err := composer.GenerateData(pl); err != nil {
+		return fmt.Errorf("error generating policy data: %w", err)
+	}
+
+	if p.config.PolicyTestsEnabled() {
+		if err := composer.TestPolicySet(ctx, pl, p.config.MinCoveragePercent()); err != nil {
+			return fmt.Errorf("error testing policies: %w", err)
+		}
+	}
+
 	if p.config.Bundle != "" {
 		logger.Info(fmt.Sprintf("Creating policy bundle at %s", p.config.Bundle))
-		if err := composer.Bundle(context.Background(), *p.config); err != nil {
+		if err := composer.Bundle(ctx, pl, *p.config); err != nil {
 			return fmt.Errorf("error creating policy bundle: %w", err)
 		}
+		if p.config.BundleVerificationKey != "" {
+			if _, err := LoadBundle(p.config.Bundle, *p.config); err != nil {
+				return fmt.Errorf("error verifying policy bundle: %w", err)
+			}
+			logger.Info("Verified policy bundle signature", "bundle", p.config.Bundle)
+		}
 	}
 	return nil
 }
@@ -83,81 +125,116 @@ func (p *Plugin) GetResults(ctx context.Context, pl policy.Policy) (policy.PVPRe
 		Links:               []policy.Link{},
 	}
 
-	// Check if Loki client is configured
-	if p.lokiClient == nil {
-		logger.Warn("Loki client not configured, returning empty result")
+	// Check if an evidence source is configured
+	if p.evidence == nil {
+		logger.Warn("Evidence source not configured, returning empty result")
 		return result, nil
 	}
 
-	// Process each rule set in the policy
+	window, err := evidenceWindow(*p.config, time.Now())
+	if err != nil {
+		return result, fmt.Errorf("error determining the assessment window: %w", err)
+	}
+	logger.Info("Querying evidence in assessment window", "window", window.String())
+
+	var checkIDs []string
+	seen := make(map[string]struct{})
 	for _, ruleset := range pl {
 		for _, check := range ruleset.Checks {
-			policyID := check.ID
+			if _, ok := seen[check.ID]; ok {
+				continue
+			}
+			seen[check.ID] = struct{}{}
+			checkIDs = append(checkIDs, check.ID)
+		}
+	}
 
-			logger.Info("Querying Loki for policy", "policy_id", policyID)
+	if p.config.DryRunEnabled() {
+		describeQueries(p.evidence, checkIDs, window)
+		return result, nil
+	}
 
-			// Query Loki for logs related to this policy
-			logEntries, err := p.lokiClient.QueryLogs(ctx, policyID, 100)
+	// Query the evidence for each check in the policy, a bounded number at a time
+	if source, ok := p.evidence.(runAware); ok {
+		source.startRun()
+	}
+	type checkEvidence struct {
+		records []EvidenceRecord
+		err     error
+	}
+	queried := make([]checkEvidence, len(checkIDs))
+	sem := make(chan struct{}, p.config.EvidenceQueryConcurrency())
+	var wg sync.WaitGroup
+	for i, checkID := range checkIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			logger.Info("Querying evidence for policy", "policy_id", checkID, "source", p.evidence.Name())
+			records, err := p.evidence.Query(ctx, checkID, window)
 			if err != nil {
-				logger.Error("Failed to query Loki", "error", err, "policy_id", policyID)
-				continue // Continue with other policies even if one fails
+				logger.Error("Failed to query evidence", "error", err, "policy_id", checkID, "source", p.evidence.Name())
+			} else {
+				logger.Info("Retrieved evidence records", "count", len(records), "policy_id", checkID)
 			}
+			queried[i] = checkEvidence{records: records, err: err}
+		}()
+	}
+	wg.Wait()
 
-			logger.Info("Retrieved log entries", "count", len(logEntries), "policy_id", policyID)
+	evidence := make(map[string][]EvidenceRecord)
+	for i, checkID := range checkIDs {
+		if queried[i].err == nil {
+			evidence[checkID] = queried[i].records
+		}
+	}
 
-			// Process log entries into evidence links
-			var evidenceLinks []policy.Link
-			for _, entry := range logEntries {
-				// Create evidence link from log entry
-				evidenceLink := policy.Link{
-					Description: fmt.Sprintf("Evidence from Loki log entry at %s", entry.Timestamp),
-					Href: fmt.Sprintf("%s/loki/api/v1/query_range?query={policy_id=\"%s\"}&start=%s&end=%s",
-						p.lokiClient.baseURL, policyID, entry.Timestamp, entry.Timestamp),
+	// Archive the fetched evidence and link each record to its copy in the archive
+	var snapshot *evidenceSnapshot
+	if p.config.EvidenceSnapshot != "" {
+		snapshot = newEvidenceSnapshot(p.config.EvidenceSnapshot, time.Now())
+		for _, checkID := range checkIDs {
+			for i := range evidence[checkID] {
+				if err := snapshot.add(p.evidence.Name(), &evidence[checkID][i]); err != nil {
+					return result, err
 				}
-				evidenceLinks = append(evidenceLinks, evidenceLink)
 			}
+		}
+	}
 
-			// Create observation for this rule set
-			if len(evidenceLinks) > 0 {
-				observation := policy.ObservationByCheck{
-					Title:             fmt.Sprintf("Policy Check for %s", policyID),
-					Description:       fmt.Sprintf("Evidence collected from Loki for policy %s", policyID),
-					CheckID:           policyID,
-					Methods:           []string{"TEST"},
-					Subjects:          []policy.Subject{},
-					Collected:         time.Now(),
-					RelevantEvidences: evidenceLinks,
-					Props: []policy.Property{
-						{Name: "source", Value: "loki"},
-						{Name: "log_count", Value: fmt.Sprintf("%d", len(logEntries))},
-						{Name: "policy_id", Value: policyID},
-					},
-				}
-
-				// Add subjects for each log entry
-				for i, entry := range logEntries {
-					// Extract policy_status_detail from labels as the reason
-					reason := extractReason(entry.Labels)
-					
-					subject := policy.Subject{
-						Title:       fmt.Sprintf("Log Entry %d", i+1),
-						Type:        "resource",
-						ResourceID:  fmt.Sprintf("log-%s-%d", policyID, i),
-						Result:      mapResults(entry.Labels),
-						EvaluatedOn: time.Now(),
-						Reason:      reason,
-						Props: []policy.Property{
-							{Name: "timestamp", Value: entry.Timestamp},
-							{Name: "message", Value: entry.Message},
-							{Name: "labels", Value: fmt.Sprintf("%v", entry.Labels)},
-						},
-					}
-					observation.Subjects = append(observation.Subjects, subject)
-				}
+	// Create an observation for each check from its latest evaluation run. Checks whose
+	// evidence could not be fetched are reported as errored, and checks without evidence
+	// in the window as not satisfied.
+	for i, checkID := range checkIDs {
+		var observation policy.ObservationByCheck
+		run, ok := latestRun(evidence[checkID])
+		switch {
+		case queried[i].err != nil:
+			observation = erroredObservation(p.evidence.Name(), checkID, queried[i].err)
+		case !ok:
+			logger.Warn("No evidence collected for check", "policy_id", checkID, "source", p.evidence.Name())
+			observation = noEvidenceObservation(p.evidence, checkID, window)
+		case p.subjects != nil:
+			result.ObservationsByCheck = append(result.ObservationsByCheck, p.subjectObservations(checkID, run, evidence[checkID])...)
+			continue
+		default:
+			observation = runObservation(p.evidence.Name(), checkID, run, evidence[checkID])
+		}
+		result.ObservationsByCheck = append(result.ObservationsByCheck, observation)
+	}
 
-				result.ObservationsByCheck = append(result.ObservationsByCheck, observation)
-			}
+	if snapshot != nil {
+		digest, err := snapshot.write()
+		if err != nil {
+			return result, err
 		}
+		result.Links = append(result.Links, policy.Link{
+			Description: fmt.Sprintf("Evidence snapshot %s", digest),
+			Href:        filepath.Base(p.config.EvidenceSnapshot),
+		})
+		logger.Info("Wrote evidence snapshot", "path", p.config.EvidenceSnapshot, "digest", digest, "records", len(snapshot.records))
 	}
 
 	logger.Info("GetResults completed",
@@ -167,26 +244,60 @@ func (p *Plugin) GetResults(ctx context.Context, pl policy.Policy) (policy.PVPRe
 	return result, nil
 }
 
-func mapResults(labels map[string]string) policy.Result {
-	status, ok := labels["compliance_status"]
+// checkEvidenceSource verifies that the evidence source is reachable and accepts the
+// configured credentials, so misconfiguration fails Configure instead of every query.
+func checkEvidenceSource(ctx context.Context, evidence EvidenceSource) error {
+	checker, ok := evidence.(healthChecker)
 	if !ok {
-		return policy.ResultError
+		logger.Info("Evidence source does not support health checks", "source", evidence.Name())
+		return nil
 	}
-	switch status {
-	case "Fail":
-		return policy.ResultFail
-	case "Pass":
-		return policy.ResultPass
-	default:
-		return policy.ResultError
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	if err := checker.checkHealth(ctx); err != nil {
+		return fmt.Errorf("evidence source %s health check failed: %w", evidence.Name(), err)
 	}
+	logger.Info("Evidence source health check passed", "source", evidence.Name())
+	return nil
 }
 
-// extractReason extracts the policy_status_detail from labels as the reason for the OSCAL subject
-func extractReason(labels map[string]string) string {
-	if reason, ok := labels["policy_status_detail"]; ok && reason != "" {
-		return reason
+// describeQueries logs the queries GetResults would run for each check in a dry run.
+func describeQueries(evidence EvidenceSource, checkIDs []string, window TimeWindow) {
+	describer, ok := evidence.(queryDescriber)
+	for _, checkID := range checkIDs {
+		args := []any{"policy_id", checkID, "source", evidence.Name(), "window", window.String()}
+		if ok {
+			details, err := describer.describeQuery(checkID, window)
+			if err != nil {
+				logger.Error("Dry run: invalid evidence query", append(args, "error", err)...)
+				continue
+			}
+			args = append(args, details...)
+		}
+		logger.Info("Dry run: evidence query", args...)
+	}
+	logger.Info("Dry run completed, no evidence was queried", "checks", len(checkIDs))
+}
+
+// subjectObservations creates the observations for a check with the evidence mapped to
+// assessment plan subjects. Evidence about subjects unknown to the plan is reported in a
+// separate observation.
+func (p *Plugin) subjectObservations(checkID string, run evaluationRun, records []EvidenceRecord) []policy.ObservationByCheck {
+	mapped, unknown, subjects := p.subjects.partition(records)
+	var observations []policy.ObservationByCheck
+	if len(mapped) > 0 {
+		observation := runObservation(p.evidence.Name(), checkID, run, mapped)
+		applySubjects(&observation, subjects)
+		observations = append(observations, observation)
+	}
+	if len(unknown) > 0 {
+		observation := unknownSubjectsObservation(p.evidence.Name(), checkID, run, unknown)
+		resources := make([]string, 0, len(observation.Subjects))
+		for _, subject := range observation.Subjects {
+			resources = append(resources, subject.ResourceID)
+		}
+		logger.Warn("Evidence for subjects not in the assessment plan", "policy_id", checkID, "subjects", strings.Join(resources, ", "))
+		observations = append(observations, observation)
 	}
-	// Fallback to default reason if policy_status_detail is not available
-	return "Evidence found in Loki logs"
+	return observations
 }
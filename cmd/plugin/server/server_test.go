@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+	"github.com/oscal-compass/oscal-sdk-go/extensions"
+)
+
+// conftestLogEntry returns a log entry as conftest-exporter writes it through ProofWatch: a
+// finding, or a completed scan summarizing the run when summary is set.
+func conftestLogEntry(t *testing.T, traceID string, at time.Time, summary bool, statusID int32, message string) fakeLokiEntry {
+	t.Helper()
+	event := map[string]any{
+		"time":        at.UnixMilli(),
+		"status_id":   statusID,
+		"message":     message,
+		"policy":      map[string]string{"uid": "check-1"},
+		"observables": []map[string]string{{"name": "file.name", "value": "input.json"}},
+	}
+	if summary {
+		event["activity_id"] = ocsfActivityCompleted
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fakeLokiEntry{
+		ts:     at.UnixNano(),
+		line:   string(line),
+		labels: map[string]string{"policy_id": "check-1", "trace_id": traceID},
+	}
+}
+
+func TestGetResultsLatestRun(t *testing.T) {
+	now := time.Now()
+	earlier, later := now.Add(-40*time.Minute), now.Add(-20*time.Minute)
+
+	tests := []struct {
+		name       string
+		entries    func(t *testing.T) []fakeLokiEntry
+		wantResult policy.Result
+		wantReason string
+		wantRunID  string
+	}{
+		{
+			name: "clean run replaces earlier failure",
+			entries: func(t *testing.T) []fakeLokiEntry {
+				return []fakeLokiEntry{
+					conftestLogEntry(t, "run-1", earlier, false, ocsfStatusFailure, "branch is not protected"),
+					conftestLogEntry(t, "run-1", earlier, true, ocsfStatusFailure, "1 failures and 0 warnings found"),
+					conftestLogEntry(t, "run-2", later, true, ocsfStatusSuccess, "No violations found (3 successes)"),
+				}
+			},
+			wantResult: policy.ResultPass,
+			wantReason: "No violations found (3 successes)",
+			wantRunID:  "run-2",
+		},
+		{
+			name: "check that never failed passes",
+			entries: func(t *testing.T) []fakeLokiEntry {
+				return []fakeLokiEntry{
+					conftestLogEntry(t, "run-1", later, true, ocsfStatusSuccess, "No violations found (3 successes)"),
+				}
+			},
+			wantResult: policy.ResultPass,
+			wantReason: "No violations found (3 successes)",
+			wantRunID:  "run-1",
+		},
+		{
+			name: "failure in latest run is reported without its summary",
+			entries: func(t *testing.T) []fakeLokiEntry {
+				return []fakeLokiEntry{
+					conftestLogEntry(t, "run-1", earlier, true, ocsfStatusSuccess, "No violations found (3 successes)"),
+					conftestLogEntry(t, "run-2", later, false, ocsfStatusFailure, "branch is not protected"),
+					conftestLogEntry(t, "run-2", later, true, ocsfStatusFailure, "1 failures and 0 warnings found"),
+				}
+			},
+			wantResult: policy.ResultFail,
+			wantReason: "branch is not protected",
+			wantRunID:  "run-2",
+		},
+		{
+			name: "failures without summaries from an earlier run are resolved",
+			entries: func(t *testing.T) []fakeLokiEntry {
+				return []fakeLokiEntry{
+					conftestLogEntry(t, "run-1", earlier, false, ocsfStatusFailure, "branch is not protected"),
+					conftestLogEntry(t, "run-2", later, true, ocsfStatusSuccess, "No violations found (3 successes)"),
+				}
+			},
+			wantResult: policy.ResultPass,
+			wantReason: "No violations found (3 successes)",
+			wantRunID:  "run-2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loki := httptest.NewServer(&fakeLoki{entries: tt.entries(t)})
+			t.Cleanup(loki.Close)
+			config := &Config{LokiURL: loki.URL}
+			source, err := newLokiSource(*config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plugin := &Plugin{config: config, evidence: source}
+
+			result, err := plugin.GetResults(context.Background(), policy.Policy{{Checks: []extensions.Check{{ID: "check-1"}}}})
+			if err != nil {
+				t.Fatalf("GetResults() error = %v", err)
+			}
+			if len(result.ObservationsByCheck) != 1 {
+				t.Fatalf("GetResults() observations = %d, want 1", len(result.ObservationsByCheck))
+			}
+			subjects := result.ObservationsByCheck[0].Subjects
+			if len(subjects) != 1 {
+				t.Fatalf("subjects = %+v, want one subject", subjects)
+			}
+			subject := subjects[0]
+			if subject.ResourceID != "input.json" || subject.Result != tt.wantResult || subject.Reason != tt.wantReason {
+				t.Errorf("subject = %s %s %q, want input.json %s %q", subject.ResourceID, subject.Result, subject.Reason, tt.wantResult, tt.wantReason)
+			}
+			if got := propValue(subject.Props, "run_id"); got != tt.wantRunID {
+				t.Errorf("run_id = %q, want %q", got, tt.wantRunID)
+			}
+		})
+	}
+}
+
+// propValue returns the value of the last property with the name.
+func propValue(props []policy.Property, name string) string {
+	var value string
+	for _, prop := range props {
+		if prop.Name == name {
+			value = prop.Value
+		}
+	}
+	return value
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+// snapshotIndexFile is the name of the index in an evidence snapshot archive.
+const snapshotIndexFile = "index.json"
+
+// evidenceSnapshot collects the evidence fetched in a GetResults run into a content-addressed
+// archive, so assessment results can be verified after the source's retention lapses.
+// Each record is stored once under records/<sha256>.json and listed in index.json.
+type evidenceSnapshot struct {
+	path    string
+	created time.Time
+	records map[string][]byte
+	// entries indexes the entries of index by record hash.
+	entries map[string]*snapshotEntry
+	index   []*snapshotEntry
+}
+
+// snapshotEntry describes a record in the snapshot index. The same content can be evidence
+// for several checks and subjects, so every reference to it is listed.
+type snapshotEntry struct {
+	SHA256     string              `json:"sha256"`
+	Path       string              `json:"path"`
+	Source     string              `json:"source"`
+	References []snapshotReference `json:"references"`
+}
+
+// snapshotReference is a check and subject a record is evidence for.
+type snapshotReference struct {
+	CheckID   string `json:"check_id"`
+	SubjectID string `json:"subject_id,omitempty"`
+	RunID     string `json:"run_id,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// snapshotIndex is the index.json document of a snapshot archive.
+type snapshotIndex struct {
+	Created string           `json:"created"`
+	Records []*snapshotEntry `json:"records"`
+}
+
+func newEvidenceSnapshot(path string, created time.Time) *evidenceSnapshot {
+	return &evidenceSnapshot{
+		path:    filepath.Clean(path),
+		created: created.UTC(),
+		records: make(map[string][]byte),
+		entries: make(map[string]*snapshotEntry),
+	}
+}
+
+// add stores the raw evidence of a record and links the record to it. Records without
+// raw evidence are stored as their normalized JSON.
+func (s *evidenceSnapshot) add(source string, record *EvidenceRecord) error {
+	data := record.Raw
+	if data == nil {
+		var err error
+		if data, err = json.Marshal(record); err != nil {
+			return fmt.Errorf("failed to encode evidence for check %s: %w", record.CheckID, err)
+		}
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	recordPath := "records/" + hash + ".json"
+
+	entry, ok := s.entries[hash]
+	if !ok {
+		s.records[hash] = data
+		entry = &snapshotEntry{SHA256: hash, Path: recordPath, Source: source}
+		s.entries[hash] = entry
+		s.index = append(s.index, entry)
+	}
+	reference := snapshotReference{
+		CheckID:   record.CheckID,
+		SubjectID: record.SubjectID,
+		RunID:     record.RunID,
+	}
+	if !record.Timestamp.IsZero() {
+		reference.Timestamp = record.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	if !slices.Contains(entry.References, reference) {
+		entry.References = append(entry.References, reference)
+	}
+
+	record.Links = append(record.Links, policy.Link{
+		Description: fmt.Sprintf("Evidence snapshot record sha256:%s", hash),
+		Href:        filepath.Base(s.path) + "#" + recordPath,
+	})
+	return nil
+}
+
+// write writes the snapshot as a gzipped tarball and returns the SHA-256 digest of the
+// archive. The archive is written to a temporary file first so a failed run does not leave
+// a partial snapshot behind.
+func (s *evidenceSnapshot) write() (string, error) {
+	index, err := json.MarshalIndent(snapshotIndex{
+		Created: s.created.Format(time.RFC3339),
+		Records: s.index,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".evidence-snapshot-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	digest := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(tmp, digest))
+	tw := tar.NewWriter(gz)
+	addFile := func(name string, data []byte) error {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0o600,
+			Size:    int64(len(data)),
+			ModTime: s.created,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	err = addFile(snapshotIndexFile, index)
+	hashes := make([]string, 0, len(s.records))
+	for hash := range s.records {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		if err != nil {
+			break
+		}
+		err = addFile("records/"+hash+".json", s.records[hash])
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write evidence snapshot %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return "", fmt.Errorf("failed to write evidence snapshot %s: %w", s.path, err)
+	}
+	return "sha256:" + hex.EncodeToString(digest.Sum(nil)), nil
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+// readTarGz returns the contents of every file in a gzipped tarball keyed by name, in
+// archive order.
+func readTarGz(t *testing.T, data []byte) ([]string, map[string]string) {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return names, files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		files[header.Name] = string(content)
+	}
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestEvidenceSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	snapshot := newEvidenceSnapshot(filepath.Join(dir, "evidence.tar.gz"), created)
+
+	at := created.Add(-time.Hour)
+	raw := `{"line": "branch main is not protected"}`
+	records := []EvidenceRecord{
+		{CheckID: "check-1", SubjectID: "repo-a", RunID: "run-1", Timestamp: at, Raw: []byte(raw)},
+		// The same log line is evidence for a second check and subject.
+		{CheckID: "check-2", SubjectID: "repo-b", Timestamp: at, Raw: []byte(raw)},
+		// A repeated reference is listed once.
+		{CheckID: "check-1", SubjectID: "repo-a", RunID: "run-1", Timestamp: at, Raw: []byte(raw)},
+		// Records without raw evidence are stored as their normalized JSON.
+		{CheckID: "check-3", SubjectID: "plan.json", Result: policy.ResultPass, Reason: "No violations found"},
+	}
+	for i := range records {
+		if err := snapshot.add("local", &records[i]); err != nil {
+			t.Fatalf("add() error = %v", err)
+		}
+	}
+	normalized, err := json.Marshal(EvidenceRecord{CheckID: "check-3", SubjectID: "plan.json", Result: policy.ResultPass, Reason: "No violations found"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawHash, normalizedHash := sha256Hex(raw), sha256Hex(string(normalized))
+
+	for i, wantHash := range []string{rawHash, rawHash, rawHash, normalizedHash} {
+		want := []policy.Link{{
+			Description: "Evidence snapshot record sha256:" + wantHash,
+			Href:        "evidence.tar.gz#records/" + wantHash + ".json",
+		}}
+		if !reflect.DeepEqual(records[i].Links, want) {
+			t.Errorf("record %d links = %v, want %v", i, records[i].Links, want)
+		}
+	}
+
+	digest, err := snapshot.write()
+	if err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	archive, err := os.ReadFile(filepath.Join(dir, "evidence.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sha256:" + sha256Hex(string(archive)); digest != want {
+		t.Errorf("write() digest = %s, want %s", digest, want)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("write() left %v in the snapshot directory, want only the archive", entries)
+	}
+
+	names, files := readTarGz(t, archive)
+	recordFiles := []string{"records/" + rawHash + ".json", "records/" + normalizedHash + ".json"}
+	if normalizedHash < rawHash {
+		recordFiles[0], recordFiles[1] = recordFiles[1], recordFiles[0]
+	}
+	if want := append([]string{snapshotIndexFile}, recordFiles...); !reflect.DeepEqual(names, want) {
+		t.Errorf("archive files = %v, want %v", names, want)
+	}
+	if got := files["records/"+rawHash+".json"]; got != raw {
+		t.Errorf("raw record = %s, want %s", got, raw)
+	}
+	if got := files["records/"+normalizedHash+".json"]; got != string(normalized) {
+		t.Errorf("normalized record = %s, want %s", got, normalized)
+	}
+
+	var index snapshotIndex
+	if err := json.Unmarshal([]byte(files[snapshotIndexFile]), &index); err != nil {
+		t.Fatalf("%s: %v", snapshotIndexFile, err)
+	}
+	wantIndex := snapshotIndex{
+		Created: "2026-01-02T03:04:05Z",
+		Records: []*snapshotEntry{
+			{
+				SHA256: rawHash,
+				Path:   "records/" + rawHash + ".json",
+				Source: "local",
+				References: []snapshotReference{
+					{CheckID: "check-1", SubjectID: "repo-a", RunID: "run-1", Timestamp: "2026-01-02T02:04:05Z"},
+					{CheckID: "check-2", SubjectID: "repo-b", Timestamp: "2026-01-02T02:04:05Z"},
+				},
+			},
+			{
+				SHA256:     normalizedHash,
+				Path:       "records/" + normalizedHash + ".json",
+				Source:     "local",
+				References: []snapshotReference{{CheckID: "check-3", SubjectID: "plan.json"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(index, wantIndex) {
+		got, _ := json.Marshal(index)
+		want, _ := json.Marshal(wantIndex)
+		t.Errorf("%s = %s, want %s", snapshotIndexFile, got, want)
+	}
+
+	t.Run("reproducible", func(t *testing.T) {
+		again := newEvidenceSnapshot(filepath.Join(t.TempDir(), "evidence.tar.gz"), created)
+		for _, record := range records {
+			record.Links = nil
+			if err := again.add("local", &record); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if againDigest, err := again.write(); err != nil || againDigest != digest {
+			t.Errorf("write() = %s, %v for the same evidence, want %s", againDigest, err, digest)
+		}
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		missing := newEvidenceSnapshot(filepath.Join(dir, "missing", "evidence.tar.gz"), created)
+		if _, err := missing.write(); err == nil {
+			t.Error("write() succeeded, want an error")
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	cp "github.com/otiai10/copy"
+)
+
+// errCheckNotFound is returned by a PolicySource when no policy exists for a check ID.
+var errCheckNotFound = errors.New("check not found in policy source")
+
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// PolicySource resolves the Rego policy directory for a check ID.
+type PolicySource interface {
+	// Fetch writes the policy directory for the check ID to dest.
+	// It returns errCheckNotFound if the source has no policy for the check.
+	Fetch(ctx context.Context, checkID, dest string) error
+	// Close releases any resources held by the source.
+	Close() error
+}
+
+// NewPolicySource creates a PolicySource from a source reference. Supported references are
+// oci://<registry>/<repository>[:<tag>|@<digest>], git::<url>[//<subdir>][?ref=<ref>],
+// and file://<path> or a plain directory path.
+func NewPolicySource(config Config) (PolicySource, error) {
+	ref := config.PolicySource
+	switch {
+	case strings.HasPrefix(ref, "oci://"):
+		return newOCISource(strings.TrimPrefix(ref, "oci://"), config)
+	case strings.HasPrefix(ref, "git::"):
+		return newGitSource(strings.TrimPrefix(ref, "git::"), config.PolicySourceDigest)
+	case strings.HasPrefix(ref, "file://"):
+		return &dirSource{root: strings.TrimPrefix(ref, "file://")}, nil
+	case ref == "":
+		return nil, errors.New("policy source must not be empty")
+	default:
+		if strings.Contains(ref, "://") {
+			return nil, fmt.Errorf("unsupported policy source %q", ref)
+		}
+		return &dirSource{root: ref}, nil
+	}
+}
+
+// dirSource resolves checks from a local directory. A check's policy is the
+// directory named after the check ID, either directly under the root or nested within it.
+type dirSource struct {
+	root string
+}
+
+func (d *dirSource) Fetch(_ context.Context, checkID, dest string) error {
+	origPath, err := findCheckDir(d.root, checkID)
+	if err != nil {
+		return err
+	}
+	return cp.Copy(origPath, dest)
+}
+
+func (d *dirSource) Close() error {
+	return nil
+}
+
+// validateCheckID rejects check IDs that are not a single clean path element, since the
+// check ID names the policy directory read from the source and written to the output.
+func validateCheckID(checkID string) error {
+	if checkID == "" || checkID == "." || checkID == ".." || strings.ContainsAny(checkID, `/\`) ||
+		filepath.Base(checkID) != checkID || filepath.IsAbs(checkID) || filepath.VolumeName(checkID) != "" {
+		return fmt.Errorf("invalid check ID %q: must be a single path element", checkID)
+	}
+	return nil
+}
+
+func findCheckDir(root, checkID string) (string, error) {
+	if err := validateCheckID(checkID); err != nil {
+		return "", err
+	}
+	direct := filepath.Join(root, checkID)
+	if info, err := os.Stat(direct); err == nil && info.IsDir() {
+		return direct, nil
+	}
+
+	var found string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && entry.Name() == checkID {
+			found = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", errCheckNotFound
+	}
+	return found, nil
+}
+
+// fetchedSource downloads a remote source into a temporary directory once
+// and resolves checks from the downloaded tree.
+type fetchedSource struct {
+	once     sync.Once
+	download func(ctx context.Context, dir string) error
+	dir      string
+	err      error
+}
+
+func (f *fetchedSource) Fetch(ctx context.Context, checkID, dest string) error {
+	f.once.Do(func() {
+		f.dir, f.err = os.MkdirTemp("", "policy-source-")
+		if f.err != nil {
+			return
+		}
+		f.err = f.download(ctx, f.dir)
+	})
+	if f.err != nil {
+		return f.err
+	}
+	origPath, err := findCheckDir(f.dir, checkID)
+	if err != nil {
+		return err
+	}
+	return cp.Copy(origPath, dest)
+}
+
+func (f *fetchedSource) Close() error {
+	if f.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(f.dir)
+}
+
+// newGitSource creates a source that clones a git repository. The reference takes the
+// form <url>[//<subdir>][?ref=<branch, tag, or commit>]. If expectedCommit is set, the
+// checked out commit must match it.
+func newGitSource(ref, expectedCommit string) (PolicySource, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid git policy source %q: %w", ref, err)
+	}
+	revision := u.Query().Get("ref")
+	u.RawQuery = ""
+
+	var subdir string
+	if idx := strings.Index(u.Path, "//"); idx >= 0 {
+		subdir = u.Path[idx+2:]
+		u.Path = u.Path[:idx]
+	}
+	repoURL := u.String()
+	if u.Scheme == "" {
+		// Local repository paths are used as a stand-in for remote repositories.
+		repoURL = u.Path
+	}
+
+	source := &fetchedSource{}
+	source.download = func(ctx context.Context, dir string) error {
+		args := []string{"clone", "--quiet"}
+		if revision != "" && !commitPattern.MatchString(revision) {
+			args = append(args, "--depth", "1", "--branch", revision)
+		}
+		// "--" keeps a URL starting with "-" from being read as an option.
+		args = append(args, "--", repoURL, dir)
+		if err := runGit(ctx, "", args...); err != nil {
+			return err
+		}
+		if commitPattern.MatchString(revision) {
+			if err := runGit(ctx, dir, "checkout", "--quiet", revision); err != nil {
+				return err
+			}
+		}
+		if expectedCommit != "" {
+			head, err := gitOutput(ctx, dir, "rev-parse", "HEAD")
+			if err != nil {
+				return err
+			}
+			if head != expectedCommit {
+				return fmt.Errorf("policy source commit %s does not match expected %s", head, expectedCommit)
+			}
+		}
+		if subdir != "" {
+			return narrowTo(dir, subdir)
+		}
+		return os.RemoveAll(filepath.Join(dir, ".git"))
+	}
+	return source, nil
+}
+
+// narrowTo replaces the contents of dir with the contents of dir/subdir.
+func narrowTo(dir, subdir string) error {
+	cleaned := filepath.Clean(subdir)
+	if filepath.IsAbs(cleaned) || strings.HasPrefix(cleaned, "..") {
+		return fmt.Errorf("invalid policy source subdirectory %q", subdir)
+	}
+	tmp, err := os.MkdirTemp("", "policy-source-subdir-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := cp.Copy(filepath.Join(dir, cleaned), tmp); err != nil {
+		return fmt.Errorf("policy source subdirectory %q: %w", subdir, err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return cp.Copy(tmp, dir)
+}
+
+func runGit(ctx context.Context, dir string, args ...string) error {
+	_, err := gitOutput(ctx, dir, args...)
+	return err
+}
+
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
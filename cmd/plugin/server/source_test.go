@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes files, keyed by slash-separated path, under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFiles returns the contents of every file under dir keyed by slash-separated path.
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestValidateCheckID(t *testing.T) {
+	tests := []struct {
+		checkID string
+		wantErr bool
+	}{
+		{checkID: "github_branch_protection"},
+		{checkID: "check-1.v2"},
+		{checkID: "..check"},
+		{checkID: "", wantErr: true},
+		{checkID: ".", wantErr: true},
+		{checkID: "..", wantErr: true},
+		{checkID: "../x", wantErr: true},
+		{checkID: "x/..", wantErr: true},
+		{checkID: "a/b", wantErr: true},
+		{checkID: `a\b`, wantErr: true},
+		{checkID: "/etc", wantErr: true},
+		{checkID: `..\x`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.checkID, func(t *testing.T) {
+			if err := validateCheckID(tt.checkID); (err != nil) != tt.wantErr {
+				t.Errorf("validateCheckID(%q) error = %v, wantErr %v", tt.checkID, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewPolicySource(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "./policies", want: "*server.dirSource"},
+		{ref: "file:///tmp/policies", want: "*server.dirSource"},
+		{ref: "git::https://example.com/org/repo.git//policies?ref=v1", want: "*server.fetchedSource"},
+		{ref: "oci://localhost:5000/policies:v1", want: "*server.fetchedSource"},
+		{ref: "", wantErr: true},
+		{ref: "s3://bucket/policies", wantErr: true},
+		{ref: "oci://localhost", wantErr: true},
+		{ref: "oci://localhost/policies@md5:abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			source, err := NewPolicySource(Config{PolicySource: tt.ref})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPolicySource(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			}
+			if err == nil {
+				if got := fmt.Sprintf("%T", source); got != tt.want {
+					t.Errorf("NewPolicySource(%q) = %s, want %s", tt.ref, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDirSourceFetch(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"direct/policy.rego":                 "package main\n",
+		"nested/group/deep/policy.rego":      "package deep\n",
+		"nested/group/deep/policy_test.rego": "package deep_test\n",
+		"outside/policy.rego":                "package outside\n",
+	})
+	source := &dirSource{root: filepath.Join(root, "direct", "..")}
+
+	tests := []struct {
+		checkID string
+		want    map[string]string
+		wantErr error
+	}{
+		{checkID: "direct", want: map[string]string{"policy.rego": "package main\n"}},
+		{checkID: "deep", want: map[string]string{"policy.rego": "package deep\n", "policy_test.rego": "package deep_test\n"}},
+		{checkID: "missing", wantErr: errCheckNotFound},
+		{checkID: "../outside"},
+		{checkID: ".."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.checkID, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "out")
+			err := source.Fetch(context.Background(), tt.checkID, dest)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("Fetch(%q) succeeded, want an error", tt.checkID)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("Fetch(%q) error = %v, want %v", tt.checkID, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch(%q) error = %v", tt.checkID, err)
+			}
+			if got := readFiles(t, dest); !equalFiles(got, tt.want) {
+				t.Errorf("Fetch(%q) wrote %v, want %v", tt.checkID, got, tt.want)
+			}
+		})
+	}
+}
+
+func equalFiles(got, want map[string]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for name, content := range want {
+		if got[name] != content {
+			return false
+		}
+	}
+	return true
+}
+
+// git runs a git command in dir for setting up test repositories.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGitSourceFetch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	// A local repository stands in for a remote one. v1 is tagged before the policy changes.
+	repo := t.TempDir()
+	git(t, repo, "init", "--quiet")
+	writeFiles(t, repo, map[string]string{
+		"policies/check-1/policy.rego": "package main # v1\n",
+		"README.md":                    "policies\n",
+	})
+	git(t, repo, "add", "-A")
+	git(t, repo, "commit", "--quiet", "-m", "v1")
+	git(t, repo, "tag", "v1")
+	first := git(t, repo, "rev-parse", "HEAD")
+	writeFiles(t, repo, map[string]string{"policies/check-1/policy.rego": "package main # v2\n"})
+	git(t, repo, "commit", "--quiet", "-am", "v2")
+	head := git(t, repo, "rev-parse", "HEAD")
+
+	tests := []struct {
+		name     string
+		ref      string
+		digest   string
+		checkID  string
+		want     string
+		wantErr  string
+		wantMiss bool
+	}{
+		{name: "default branch", ref: repo, checkID: "check-1", want: "package main # v2\n"},
+		{name: "tag", ref: repo + "?ref=v1", checkID: "check-1", want: "package main # v1\n"},
+		{name: "commit", ref: repo + "?ref=" + first, checkID: "check-1", want: "package main # v1\n"},
+		{name: "subdirectory", ref: repo + "//policies?ref=main", checkID: "check-1", want: "package main # v2\n"},
+		{name: "expected commit", ref: repo, digest: head, checkID: "check-1", want: "package main # v2\n"},
+		{name: "unexpected commit", ref: repo + "?ref=v1", digest: head, checkID: "check-1", wantErr: "does not match expected"},
+		{name: "subdirectory outside repository", ref: repo + "//../x", checkID: "check-1", wantErr: "invalid policy source subdirectory"},
+		{name: "missing check", ref: repo, checkID: "check-2", wantMiss: true},
+		{name: "traversing check ID", ref: repo + "//policies", checkID: "../README.md", wantErr: "invalid check ID"},
+		{name: "option-like URL", ref: "--upload-pack=touch pwned", checkID: "check-1", wantErr: "repository '--upload-pack=touch pwned' does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewPolicySource(Config{PolicySource: "git::" + tt.ref, PolicySourceDigest: tt.digest})
+			if err != nil {
+				t.Fatalf("NewPolicySource() error = %v", err)
+			}
+			t.Cleanup(func() { _ = source.Close() })
+
+			dest := filepath.Join(t.TempDir(), "out")
+			err = source.Fetch(context.Background(), tt.checkID, dest)
+			switch {
+			case tt.wantMiss:
+				if !errors.Is(err, errCheckNotFound) {
+					t.Errorf("Fetch() error = %v, want %v", err, errCheckNotFound)
+				}
+				return
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Fetch() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			case err != nil:
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			files := readFiles(t, dest)
+			if files["policy.rego"] != tt.want || len(files) != 1 {
+				t.Errorf("Fetch() wrote %v, want policy.rego %q", files, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+// Subject types used for policy subjects. compliance-to-policy only accepts resources
+// and inventory items, so evidence for other assessment plan subjects is reported as a
+// resource with the plan subject type recorded in a property.
+const (
+	subjectTypeResource      = "resource"
+	subjectTypeInventoryItem = "inventory-item"
+)
+
+// subjectAttribute is the mapping key that matches the resource identified by the
+// evidence source rather than a label.
+const subjectAttribute = "resource"
+
+// planSubject is a subject declared in the assessment plan.
+type planSubject struct {
+	UUID  string
+	Type  string
+	Title string
+}
+
+// subjectMapper maps evidence to the assessment plan subjects it is about, using the
+// labels of the evidence such as repository, bucket, or fileName.
+type subjectMapper struct {
+	// attributes are the normalized label names of the mapping, in the order they are matched.
+	attributes []string
+	// subjects maps each normalized label name and value to a plan subject.
+	subjects map[string]map[string]planSubject
+}
+
+// newSubjectMapper loads the subject-mapping file and resolves its targets against the
+// subjects declared in the assessment plan. It returns nil if no mapping is configured.
+//
+// The mapping file is a JSON object from label names to label values to subject UUIDs:
+//
+//	{"repository": {"org/repo": "<subject UUID>"}, "bucket": {"logs": "<inventory item UUID>"}}
+func newSubjectMapper(config Config) (*subjectMapper, error) {
+	if config.SubjectMapping == "" {
+		return nil, nil
+	}
+	if config.AssessmentPlan == "" {
+		return nil, errors.New("assessment-plan must be provided when using subject-mapping")
+	}
+
+	data, err := os.ReadFile(filepath.Clean(config.SubjectMapping))
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject-mapping: %w", err)
+	}
+	var mapping map[string]map[string]string
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("invalid subject-mapping %s: must map label names to label values to subject UUIDs: %w", config.SubjectMapping, err)
+	}
+
+	declared, err := planSubjects(config.AssessmentPlan)
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := &subjectMapper{subjects: make(map[string]map[string]planSubject)}
+	var errs []error
+	for label, values := range mapping {
+		attribute := normalizeAttribute(label)
+		if attribute == "" {
+			errs = append(errs, fmt.Errorf("invalid subject-mapping label %q", label))
+			continue
+		}
+		if _, ok := mapper.subjects[attribute]; !ok {
+			mapper.subjects[attribute] = make(map[string]planSubject)
+			mapper.attributes = append(mapper.attributes, attribute)
+		}
+		for value, subjectUUID := range values {
+			subject, ok := declared[subjectUUID]
+			if !ok {
+				errs = append(errs, fmt.Errorf("subject-mapping %s=%s: subject %q is not declared in the assessment plan", label, value, subjectUUID))
+				continue
+			}
+			mapper.subjects[attribute][value] = subject
+		}
+	}
+	sort.Strings(mapper.attributes)
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return mapper, errors.Join(errs...)
+}
+
+// planSubjects returns the subjects declared in the assessment plan at path: the subjects
+// it includes for assessment and the components and inventory items it defines locally.
+func planSubjects(path string) (map[string]planSubject, error) {
+	plan, err := loadAssessmentPlan(path)
+	if err != nil {
+		return nil, err
+	}
+	subjects := make(map[string]planSubject)
+	if plan.LocalDefinitions != nil {
+		if components := plan.LocalDefinitions.Components; components != nil {
+			for _, component := range *components {
+				subjects[component.UUID] = planSubject{UUID: component.UUID, Type: "component", Title: component.Title}
+			}
+		}
+		if items := plan.LocalDefinitions.InventoryItems; items != nil {
+			for _, item := range *items {
+				subjects[item.UUID] = planSubject{UUID: item.UUID, Type: subjectTypeInventoryItem, Title: item.Description}
+			}
+		}
+	}
+	if plan.AssessmentSubjects != nil {
+		for _, assessmentSubject := range *plan.AssessmentSubjects {
+			if assessmentSubject.IncludeSubjects == nil {
+				continue
+			}
+			for _, include := range *assessmentSubject.IncludeSubjects {
+				if _, ok := subjects[include.SubjectUuid]; !ok {
+					subjects[include.SubjectUuid] = planSubject{UUID: include.SubjectUuid, Type: include.Type}
+				}
+			}
+		}
+	}
+	return subjects, nil
+}
+
+// normalizeAttribute lower cases a label name and removes separators, so fileName,
+// file_name, and file.name match each other.
+func normalizeAttribute(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// lookup returns the plan subject for the evidence attributes and the resource identified
+// by the evidence source. Labels are matched in alphabetical order.
+func (m *subjectMapper) lookup(attributes map[string]string, resource string) (planSubject, bool) {
+	normalized := make(map[string]string, len(attributes)+1)
+	for name, value := range attributes {
+		normalized[normalizeAttribute(name)] = value
+	}
+	normalized[subjectAttribute] = resource
+	for _, attribute := range m.attributes {
+		value, ok := normalized[attribute]
+		if !ok {
+			continue
+		}
+		if subject, ok := m.subjects[attribute][value]; ok {
+			return subject, true
+		}
+	}
+	return planSubject{}, false
+}
+
+// partition splits records into those that map to an assessment plan subject and those
+// about subjects unknown to the plan. It returns the plan subject of each mapped resource;
+// if the records of a resource map to different subjects, the first is used.
+func (m *subjectMapper) partition(records []EvidenceRecord) (mapped, unknown []EvidenceRecord, subjects map[string]planSubject) {
+	subjects = make(map[string]planSubject)
+	for _, record := range records {
+		subject, ok := subjects[record.SubjectID]
+		if !ok {
+			subject, ok = m.lookup(record.Attributes, record.SubjectID)
+		}
+		if !ok {
+			unknown = append(unknown, record)
+			continue
+		}
+		subjects[record.SubjectID] = subject
+		mapped = append(mapped, record)
+	}
+	return mapped, unknown, subjects
+}
+
+// applySubjects points the subjects of an observation at the assessment plan subjects of
+// their resources. The plan subject UUID becomes the resource ID, so every resource mapped
+// to a plan subject is reported against it, and the evidence resource is kept as a property.
+// Resources mapped to the same plan subject are merged into one subject with the most
+// severe result, so a failing resource is not hidden by a passing one.
+func applySubjects(observation *policy.ObservationByCheck, subjects map[string]planSubject) {
+	merged := make([]policy.Subject, 0, len(observation.Subjects))
+	byResourceID := make(map[string]int)
+	for _, subject := range observation.Subjects {
+		planSubject, ok := subjects[subject.ResourceID]
+		if !ok {
+			merged = append(merged, subject)
+			continue
+		}
+		evidenceResource := policy.Property{Name: "evidence_resource", Value: subject.ResourceID}
+		if i, ok := byResourceID[planSubject.UUID]; ok {
+			merged[i] = mergeSubjects(merged[i], subject, evidenceResource)
+			continue
+		}
+
+		subject.Props = append(append([]policy.Property{}, subject.Props...),
+			evidenceResource,
+			policy.Property{Name: "subject_uuid", Value: planSubject.UUID},
+			policy.Property{Name: "subject_type", Value: planSubject.Type},
+		)
+		subject.ResourceID = planSubject.UUID
+		if planSubject.Title != "" {
+			subject.Title = planSubject.Title
+		}
+		if planSubject.Type == subjectTypeInventoryItem {
+			subject.Type = subjectTypeInventoryItem
+		}
+		byResourceID[planSubject.UUID] = len(merged)
+		merged = append(merged, subject)
+	}
+	observation.Subjects = merged
+}
+
+// mergeSubjects adds the evidence of another resource to a plan subject. The most severe
+// result wins, the reasons of both are kept, and the evidence resource is recorded.
+func mergeSubjects(subject, other policy.Subject, evidenceResource policy.Property) policy.Subject {
+	if resultSeverity[other.Result] > resultSeverity[subject.Result] {
+		subject.Result = other.Result
+	}
+	if other.EvaluatedOn.After(subject.EvaluatedOn) {
+		subject.EvaluatedOn = other.EvaluatedOn
+	}
+	if other.Reason != "" && !strings.Contains(subject.Reason, other.Reason) {
+		if subject.Reason == "" {
+			subject.Reason = other.Reason
+		} else {
+			subject.Reason += "; " + other.Reason
+		}
+	}
+	subject.Props = append(subject.Props, evidenceResource)
+	return subject
+}
+
+// unknownSubjectsObservation creates the observation for the evidence of a check about
+// subjects that are not in the assessment plan, reported apart from the plan subjects.
+func unknownSubjectsObservation(source, checkID string, run evaluationRun, records []EvidenceRecord) policy.ObservationByCheck {
+	observation := runObservation(source, checkID, run, records)
+	observation.Title = fmt.Sprintf("Unmapped evidence for %s", checkID)
+	observation.Description = fmt.Sprintf("Evidence collected from %s for policy %s about subjects not declared in the assessment plan", source, checkID)
+	observation.Props = append(observation.Props, policy.Property{Name: "subject_mapping", Value: "unknown"})
+	return observation
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oscal-compass/compliance-to-policy-go/v2/policy"
+)
+
+func TestApplySubjects(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	resource := func(id string, minutes int, result policy.Result, reason string) policy.Subject {
+		return policy.Subject{
+			Title:       id,
+			Type:        subjectTypeResource,
+			ResourceID:  id,
+			Result:      result,
+			EvaluatedOn: at.Add(time.Duration(minutes) * time.Minute),
+			Reason:      reason,
+		}
+	}
+	repos := planSubject{UUID: "repos-uuid", Type: "component", Title: "Repositories"}
+	bucket := planSubject{UUID: "bucket-uuid", Type: subjectTypeInventoryItem, Title: "Log bucket"}
+	subjects := map[string]planSubject{"org/a": repos, "org/b": repos, "org/c": repos, "logs": bucket}
+
+	type want struct {
+		resourceID string
+		title      string
+		typ        string
+		result     policy.Result
+		reason     string
+		minutes    int
+		// evidence are the evidence_resource props of the subject.
+		evidence []string
+	}
+	tests := []struct {
+		name     string
+		subjects []policy.Subject
+		want     []want
+	}{
+		{
+			name: "failure is not hidden by a later pass",
+			subjects: []policy.Subject{
+				resource("org/a", 1, policy.ResultFail, "unprotected"),
+				resource("org/b", 2, policy.ResultPass, "No violations found"),
+			},
+			want: []want{{
+				resourceID: "repos-uuid", title: "Repositories", typ: subjectTypeResource, result: policy.ResultFail,
+				reason: "unprotected; No violations found", minutes: 2, evidence: []string{"org/a", "org/b"},
+			}},
+		},
+		{
+			name: "most severe result wins",
+			subjects: []policy.Subject{
+				resource("org/a", 3, policy.ResultPass, "No violations found"),
+				resource("org/b", 1, policy.ResultWarning, "stale reviews"),
+				resource("org/c", 2, policy.ResultError, "No violations found"),
+			},
+			want: []want{{
+				resourceID: "repos-uuid", title: "Repositories", typ: subjectTypeResource, result: policy.ResultError,
+				reason: "No violations found; stale reviews", minutes: 3, evidence: []string{"org/a", "org/b", "org/c"},
+			}},
+		},
+		{
+			name: "separate plan subjects and unmapped resources",
+			subjects: []policy.Subject{
+				resource("other", 1, policy.ResultFail, "unknown resource"),
+				resource("logs", 1, policy.ResultPass, "encrypted"),
+				resource("org/a", 1, policy.ResultWarning, "stale reviews"),
+			},
+			want: []want{
+				{resourceID: "other", title: "other", typ: subjectTypeResource, result: policy.ResultFail, reason: "unknown resource", minutes: 1},
+				{resourceID: "bucket-uuid", title: "Log bucket", typ: subjectTypeInventoryItem, result: policy.ResultPass, reason: "encrypted", minutes: 1, evidence: []string{"logs"}},
+				{resourceID: "repos-uuid", title: "Repositories", typ: subjectTypeResource, result: policy.ResultWarning, reason: "stale reviews", minutes: 1, evidence: []string{"org/a"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			observation := policy.ObservationByCheck{Subjects: tt.subjects}
+			applySubjects(&observation, subjects)
+			if len(observation.Subjects) != len(tt.want) {
+				t.Fatalf("applySubjects() left %d subjects, want %d: %+v", len(observation.Subjects), len(tt.want), observation.Subjects)
+			}
+			for i, want := range tt.want {
+				got := observation.Subjects[i]
+				if got.ResourceID != want.resourceID || got.Title != want.title || got.Type != want.typ {
+					t.Errorf("subject %d = %s %q (%s), want %s %q (%s)", i, got.ResourceID, got.Title, got.Type, want.resourceID, want.title, want.typ)
+				}
+				if got.Result != want.result || got.Reason != want.reason {
+					t.Errorf("subject %d = %s (%s), want %s (%s)", i, got.Result, got.Reason, want.result, want.reason)
+				}
+				if wantAt := at.Add(time.Duration(want.minutes) * time.Minute); !got.EvaluatedOn.Equal(wantAt) {
+					t.Errorf("subject %d evaluated on %v, want %v", i, got.EvaluatedOn, wantAt)
+				}
+				var evidence []string
+				for _, prop := range got.Props {
+					if prop.Name == "evidence_resource" {
+						evidence = append(evidence, prop.Value)
+					}
+				}
+				if !reflect.DeepEqual(evidence, want.evidence) {
+					t.Errorf("subject %d evidence resources = %v, want %v", i, evidence, want.evidence)
+				}
+				if want.evidence != nil && propValue(got.Props, "subject_uuid") != want.resourceID {
+					t.Errorf("subject %d subject_uuid = %q, want %s", i, propValue(got.Props, "subject_uuid"), want.resourceID)
+				}
+			}
+		})
+	}
+}
+
+func TestSubjectMapperLookup(t *testing.T) {
+	repo := planSubject{UUID: "repo-uuid", Type: "component"}
+	file := planSubject{UUID: "file-uuid", Type: subjectTypeInventoryItem}
+	mapper := &subjectMapper{
+		attributes: []string{"filename", "repository", subjectAttribute},
+		subjects: map[string]map[string]planSubject{
+			"filename":       {"branches.json": file},
+			"repository":     {"org/repo": repo},
+			subjectAttribute: {"bucket-1": file},
+		},
+	}
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		resource   string
+		want       string
+	}{
+		{name: "label", attributes: map[string]string{"repository": "org/repo"}, resource: "unknown", want: "repo-uuid"},
+		{name: "label with separators", attributes: map[string]string{"file.name": "branches.json"}, resource: "unknown", want: "file-uuid"},
+		{name: "labels in alphabetical order", attributes: map[string]string{"Repository": "org/repo", "file_name": "branches.json"}, want: "file-uuid"},
+		{name: "resource", resource: "bucket-1", want: "file-uuid"},
+		{name: "unmapped value", attributes: map[string]string{"repository": "org/other"}, resource: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, ok := mapper.lookup(tt.attributes, tt.resource)
+			if ok != (tt.want != "") || subject.UUID != tt.want {
+				t.Errorf("lookup() = %q, %v, want %q", subject.UUID, ok, tt.want)
+			}
+		})
+	}
+}
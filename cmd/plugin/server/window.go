@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	oscalTypes "github.com/defenseunicorns/go-oscal/src/types/oscal-1-1-3"
+)
+
+// parseWindowBound parses an evidence-start or evidence-end value. Values are RFC 3339
+// timestamps, "now", or durations before now such as "90m", "24h", "7d", or "2w".
+func parseWindowBound(value string, now time.Time) (time.Time, error) {
+	if value == "now" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := parseRelativeDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or a duration such as 24h or 7d", value)
+	}
+	return now.Add(-d), nil
+}
+
+// parseRelativeDuration extends time.ParseDuration with day (d) and week (w) units.
+func parseRelativeDuration(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.ParseFloat(n, 64)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			return time.Duration(count * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+// evidenceWindow returns the assessment window for evidence queries. evidence-start and
+// evidence-end take precedence; an unset end is now. If neither is set, the window is the
+// period of the assessment plan tasks. A zero window leaves the range to the evidence source.
+func evidenceWindow(config Config, now time.Time) (TimeWindow, error) {
+	if config.EvidenceStart != "" || config.EvidenceEnd != "" {
+		var window TimeWindow
+		var err error
+		if config.EvidenceStart != "" {
+			if window.Start, err = parseWindowBound(config.EvidenceStart, now); err != nil {
+				return window, fmt.Errorf("invalid evidence-start: %w", err)
+			}
+		}
+		window.End = now
+		if config.EvidenceEnd != "" {
+			if window.End, err = parseWindowBound(config.EvidenceEnd, now); err != nil {
+				return window, fmt.Errorf("invalid evidence-end: %w", err)
+			}
+		}
+		if !window.Start.IsZero() && !window.Start.Before(window.End) {
+			return window, fmt.Errorf("evidence-start %s must be before evidence-end %s", window.Start.Format(time.RFC3339), window.End.Format(time.RFC3339))
+		}
+		return window, nil
+	}
+
+	if config.AssessmentPlan == "" {
+		return TimeWindow{}, nil
+	}
+	window, err := assessmentPlanPeriod(config.AssessmentPlan, now)
+	if err != nil {
+		return TimeWindow{}, err
+	}
+	if window.Start.IsZero() && window.End.IsZero() {
+		logger.Warn("Assessment plan does not define a period, using the evidence source default window", "assessment_plan", config.AssessmentPlan)
+	}
+	return window, nil
+}
+
+// assessmentPlanPeriod returns the period covered by the timing of the tasks in the OSCAL
+// Assessment Plan at path: from the earliest start to the latest end of their date ranges
+// and dates. Tasks that only run at a frequency cover the last period ending now.
+func assessmentPlanPeriod(path string, now time.Time) (TimeWindow, error) {
+	plan, err := loadAssessmentPlan(path)
+	if err != nil {
+		return TimeWindow{}, err
+	}
+
+	var window TimeWindow
+	extend := func(start, end time.Time) {
+		if window.Start.IsZero() || start.Before(window.Start) {
+			window.Start = start
+		}
+		if window.End.IsZero() || end.After(window.End) {
+			window.End = end
+		}
+	}
+	var visit func(tasks *[]oscalTypes.Task)
+	visit = func(tasks *[]oscalTypes.Task) {
+		if tasks == nil {
+			return
+		}
+		for _, task := range *tasks {
+			if timing := task.Timing; timing != nil {
+				switch {
+				case timing.WithinDateRange != nil:
+					extend(timing.WithinDateRange.Start, timing.WithinDateRange.End)
+				case timing.OnDate != nil:
+					day := timing.OnDate.Date
+					extend(day, day.Add(24*time.Hour))
+				case timing.AtFrequency != nil:
+					if period, err := frequencyPeriod(*timing.AtFrequency); err == nil {
+						extend(now.Add(-period), now)
+					} else {
+						logger.Warn("Ignoring task timing", "task", task.Title, "error", err)
+					}
+				}
+			}
+			visit(task.Tasks)
+		}
+	}
+	visit(plan.Tasks)
+	return window, nil
+}
+
+// frequencyPeriod converts an OSCAL at-frequency condition to a duration.
+func frequencyPeriod(frequency oscalTypes.FrequencyCondition) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"seconds": time.Second,
+		"minutes": time.Minute,
+		"hours":   time.Hour,
+		"days":    24 * time.Hour,
+		"weeks":   7 * 24 * time.Hour,
+		"months":  30 * 24 * time.Hour,
+		"years":   365 * 24 * time.Hour,
+	}
+	unit, ok := units[frequency.Unit]
+	if !ok || frequency.Period <= 0 {
+		return 0, fmt.Errorf("unsupported frequency %d %s", frequency.Period, frequency.Unit)
+	}
+	return time.Duration(frequency.Period) * unit, nil
+}
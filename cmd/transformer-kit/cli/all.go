@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+// targetResult records the outcome of building a single project target.
+type targetResult struct {
+	Target   Target
+	Duration time.Duration
+	Err      error
+}
+
+func NewAllCommand() *cobra.Command {
+	var projectPath string
+	var parallelism int
+
+	command := &cobra.Command{
+		Use:   "all",
+		Short: "Build every OSCAL artifact declared in a transformer-kit project file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			project, err := LoadProject(projectPath)
+			if err != nil {
+				return err
+			}
+
+			results := buildTargets(cmd.Context(), project.Targets, parallelism)
+
+			var failed int
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "TARGET\tSTATUS\tDURATION\tDETAIL")
+			for _, result := range results {
+				status, detail := "ok", result.Target.Output
+				if result.Err != nil {
+					failed++
+					status, detail = "failed", result.Err.Error()
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Target.Name, status, result.Duration.Round(time.Millisecond), detail)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d targets failed", failed, len(results))
+			}
+			return nil
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVarP(&projectPath, "config", "f", DefaultProjectFile, "Path to the transformer-kit project file")
+	flags.IntVarP(&parallelism, "parallelism", "j", runtime.NumCPU(), "Maximum number of targets to build concurrently")
+	return command
+}
+
+// buildTargets builds all targets concurrently and returns a result for each
+// target in declaration order. A failing target does not stop the others.
+func buildTargets(ctx context.Context, targets []Target, parallelism int) []targetResult {
+	results := make([]targetResult, len(targets))
+
+	var group errgroup.Group
+	if parallelism > 0 {
+		group.SetLimit(parallelism)
+	}
+	for i, target := range targets {
+		group.Go(func() error {
+			start := time.Now()
+			err := buildTarget(ctx, target)
+			results[i] = targetResult{
+				Target:   target,
+				Duration: time.Since(start),
+				Err:      err,
+			}
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	return results
+}
+
+func buildTarget(ctx context.Context, target Target) error {
+	start := time.Now()
+	opts := target.planOptions()
+	result, err := buildAssessmentPlan(ctx, opts)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(result.Models, "", " ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(target.Output), 0750); err != nil {
+		return fmt.Errorf("failed to create output directory for %s: %w", target.Output, err)
+	}
+	if err := os.WriteFile(target.Output, data, 0600); err != nil {
+		return err
+	}
+
+	if target.Provenance {
+		statement := newProvenanceStatement(filepath.Base(target.Output), data, opts, result.Inputs, start)
+		return writeProvenance(provenancePath(target.Output), statement)
+	}
+	return nil
+}
+
+// provenancePath returns the location of the provenance statement written next to output.
+func provenancePath(output string) string {
+	return output + ".intoto.json"
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	oscalTypes "github.com/defenseunicorns/go-oscal/src/types/oscal-1-1-3"
+)
+
+// governance is the directory holding the repository's Gemara artifacts.
+const governance = "../../../governance"
+
+// governanceTarget returns a target built from the repository's Gemara artifacts.
+func governanceTarget(name, output string) Target {
+	target := Target{
+		Name:              name,
+		Title:             "GitHub Repository",
+		Catalogs:          []string{filepath.Join(governance, "catalogs", "cnscc.yaml")},
+		Plans:             []string{filepath.Join(governance, "plans", "cnscc.yaml")},
+		Policy:            filepath.Join(governance, "policy.yaml"),
+		GuidanceReference: "800-53",
+		Output:            output,
+	}
+	target.applyDefaults(Target{})
+	return target
+}
+
+func TestBuildTargets(t *testing.T) {
+	dir := t.TempDir()
+	ok := governanceTarget("ok", filepath.Join(dir, "out", "plan.json"))
+	ok.Provenance = true
+	missing := governanceTarget("missing", filepath.Join(dir, "missing.json"))
+	missing.Catalogs = []string{filepath.Join(dir, "missing.yaml")}
+	unknown := governanceTarget("unknown", filepath.Join(dir, "unknown.json"))
+	unknown.GuidanceReference = "unknown"
+
+	results := buildTargets(context.Background(), []Target{ok, missing, unknown}, 2)
+	if len(results) != 3 {
+		t.Fatalf("buildTargets() returned %d results, want 3", len(results))
+	}
+	tests := []struct {
+		result  targetResult
+		name    string
+		wantErr string
+	}{
+		{result: results[0], name: "ok"},
+		{result: results[1], name: "missing", wantErr: "no such file"},
+		{result: results[2], name: "unknown", wantErr: `guidance reference "unknown" does not exist`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.result.Target.Name != tt.name {
+				t.Fatalf("result target = %s, want %s", tt.result.Target.Name, tt.name)
+			}
+			if tt.wantErr == "" {
+				if tt.result.Err != nil {
+					t.Fatalf("build error = %v", tt.result.Err)
+				}
+				return
+			}
+			if tt.result.Err == nil || !strings.Contains(tt.result.Err.Error(), tt.wantErr) {
+				t.Errorf("build error = %v, want %q", tt.result.Err, tt.wantErr)
+			}
+			if _, err := os.Stat(tt.result.Target.Output); !os.IsNotExist(err) {
+				t.Errorf("failed target wrote %s", tt.result.Target.Output)
+			}
+		})
+	}
+
+	var models oscalTypes.OscalModels
+	data, err := os.ReadFile(ok.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &models); err != nil {
+		t.Fatal(err)
+	}
+	if models.AssessmentPlan == nil || models.AssessmentPlan.Metadata.Title == "" {
+		t.Errorf("output %s does not contain an assessment plan", ok.Output)
+	}
+	if _, err := os.Stat(provenancePath(ok.Output)); err != nil {
+		t.Errorf("provenance was not written: %v", err)
+	}
+}
+
+func TestAllCommand(t *testing.T) {
+	dir := t.TempDir()
+	governanceDir, err := filepath.Abs(governance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := `defaults:
+  catalogs: [` + filepath.Join(governanceDir, "catalogs", "cnscc.yaml") + `]
+  plans: [` + filepath.Join(governanceDir, "plans", "cnscc.yaml") + `]
+  policy: ` + filepath.Join(governanceDir, "policy.yaml") + `
+  guidance-reference: 800-53
+targets:
+  - name: repository
+    title: GitHub Repository
+    output: ./out/repository.json
+  - name: broken
+    title: Broken
+    guidance-reference: unknown
+    output: ./out/broken.json
+`
+	path := filepath.Join(dir, DefaultProjectFile)
+	if err := os.WriteFile(path, []byte(project), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	command := NewAllCommand()
+	command.SetOut(&out)
+	command.SetArgs([]string{"--config", path, "--parallelism", "1"})
+	command.SilenceUsage, command.SilenceErrors = true, true
+	err = command.ExecuteContext(context.Background())
+	if err == nil || err.Error() != "1 of 2 targets failed" {
+		t.Errorf("Execute() error = %v, want 1 of 2 targets failed", err)
+	}
+	for _, want := range []string{"repository  ok", "broken      failed", filepath.Join(dir, "out", "repository.json")} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output = %q, want %q", out.String(), want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "repository.json")); err != nil {
+		t.Errorf("repository target was not written: %v", err)
+	}
+}
@@ -0,0 +1,387 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/defenseunicorns/go-oscal/src/pkg/uuid"
+	oscalTypes "github.com/defenseunicorns/go-oscal/src/types/oscal-1-1-3"
+	"github.com/spf13/cobra"
+)
+
+const (
+	formatTable = "table"
+	formatCSV   = "csv"
+	formatJSON  = "json"
+	formatOSCAL = "oscal"
+
+	// mappingOSCALVersion is the first OSCAL release that defines the Mapping model.
+	mappingOSCALVersion = "1.2.0"
+	// defaultMappingVersion is the collection version when no loaded catalog declares one.
+	defaultMappingVersion = "0.1.0"
+	// strengthProp records the guideline mapping strength (1-10) of a mapping entry.
+	strengthProp = "strength"
+)
+
+// CrosswalkEntry relates a catalog assessment requirement to a control in
+// another framework, along with the checks that produce evidence for it.
+type CrosswalkEntry struct {
+	Source        string   `json:"source"`
+	ControlID     string   `json:"control-id"`
+	RequirementID string   `json:"requirement-id,omitempty"`
+	Reference     string   `json:"reference"`
+	ReferenceID   string   `json:"reference-id"`
+	Strength      int64    `json:"strength,omitempty"`
+	Checks        []string `json:"checks,omitempty"`
+}
+
+// Crosswalk is a mapping table built from the guideline mappings of Layer 2 catalogs.
+type Crosswalk struct {
+	Entries []CrosswalkEntry
+	// sources records the href for each catalog and mapping reference.
+	sources map[string]string
+	// versions records the version of each loaded catalog.
+	versions map[string]string
+	// lastModified is the latest last-modified time declared by a loaded catalog.
+	lastModified time.Time
+}
+
+func NewCrosswalkCommand() *cobra.Command {
+	var catalogPaths, evaluationPaths []string
+	var format, outputPath, checkID, lookupID, reference string
+
+	command := &cobra.Command{
+		Use:   "crosswalk",
+		Short: "Map catalog requirements to controls in other frameworks using guideline mappings",
+		Example: `  # Which 800-53 controls does the github_branch_protection check give evidence for?
+  transform crosswalk --check github_branch_protection --reference 800-53`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			crosswalk, err := buildCrosswalk(catalogPaths, evaluationPaths)
+			if err != nil {
+				return err
+			}
+			crosswalk = crosswalk.Filter(checkID, lookupID, reference)
+
+			out := cmd.OutOrStdout()
+			if outputPath != "" {
+				file, err := os.Create(filepath.Clean(outputPath))
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+
+			switch format {
+			case formatTable:
+				return crosswalk.WriteTable(out)
+			case formatCSV:
+				return crosswalk.WriteCSV(out)
+			case formatJSON:
+				return writeJSON(out, crosswalk.Entries)
+			case formatOSCAL:
+				return writeJSON(out, map[string]any{"mapping-collection": crosswalk.MappingCollection()})
+			default:
+				return fmt.Errorf("unsupported format %q", format)
+			}
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringSliceVarP(&catalogPaths, "catalog-path", "c", []string{"./governance/catalogs/cnscc.yaml"}, "Paths to L2 Catalogs to load")
+	flags.StringSliceVarP(&evaluationPaths, "evaluation-path", "e", []string{"./governance/plans/cnscc.yaml"}, "Paths to Layer 4 Evaluation Plans used to resolve checks")
+	flags.StringVarP(&format, "format", "f", formatTable, "Output format (table, csv, json, oscal)")
+	flags.StringVarP(&outputPath, "output", "o", "", "Path to write the crosswalk (defaults to stdout)")
+	flags.StringVar(&checkID, "check", "", "Only include entries with evidence from this check ID")
+	flags.StringVar(&lookupID, "id", "", "Only include entries where this control, requirement, or reference ID appears on either side")
+	flags.StringVar(&reference, "reference", "", "Only include entries for this mapping reference (e.g. 800-53)")
+	return command
+}
+
+// buildCrosswalk loads each catalog and records every guideline mapping entry
+// for each assessment requirement. Checks are attached from the evaluation plans.
+func buildCrosswalk(catalogPaths, evaluationPaths []string) (Crosswalk, error) {
+	checksByRequirement := make(map[string][]string)
+	for _, evaluationPath := range evaluationPaths {
+		plan, _, err := loadEvaluationPlan(evaluationPath)
+		if err != nil {
+			return Crosswalk{}, err
+		}
+		for _, controlPlan := range plan.Plans {
+			for _, assessment := range controlPlan.Assessments {
+				for _, procedure := range assessment.Procedures {
+					checksByRequirement[assessment.RequirementId] = append(checksByRequirement[assessment.RequirementId], procedure.Id)
+				}
+			}
+		}
+	}
+
+	crosswalk := Crosswalk{sources: make(map[string]string), versions: make(map[string]string)}
+	for _, catalogPath := range catalogPaths {
+		catalog, _, err := loadCatalog(catalogPath)
+		if err != nil {
+			return Crosswalk{}, err
+		}
+		source := catalog.Metadata.Id
+		crosswalk.sources[source] = catalogPath
+		crosswalk.versions[source] = catalog.Metadata.Version
+		if lastModified, err := time.Parse(time.RFC3339, catalog.Metadata.LastModified); err == nil && lastModified.After(crosswalk.lastModified) {
+			crosswalk.lastModified = lastModified
+		}
+		for _, ref := range catalog.Metadata.MappingReferences {
+			if _, ok := crosswalk.sources[ref.Id]; !ok {
+				crosswalk.sources[ref.Id] = ref.Url
+			}
+		}
+
+		for _, family := range catalog.ControlFamilies {
+			for _, control := range family.Controls {
+				requirements := []string{""}
+				if len(control.AssessmentRequirements) > 0 {
+					requirements = requirements[:0]
+					for _, requirement := range control.AssessmentRequirements {
+						requirements = append(requirements, requirement.Id)
+					}
+				}
+				for _, mapping := range control.GuidelineMappings {
+					for _, entry := range mapping.Entries {
+						for _, requirementID := range requirements {
+							crosswalk.Entries = append(crosswalk.Entries, CrosswalkEntry{
+								Source:        source,
+								ControlID:     control.Id,
+								RequirementID: requirementID,
+								Reference:     mapping.ReferenceId,
+								ReferenceID:   entry.ReferenceId,
+								Strength:      entry.Strength,
+								Checks:        checksByRequirement[requirementID],
+							})
+						}
+					}
+				}
+			}
+		}
+	}
+	return crosswalk, nil
+}
+
+// Filter returns the entries matching all non-empty criteria. The id criterion
+// matches either side of the mapping so the table can be queried in both directions.
+func (c Crosswalk) Filter(checkID, id, reference string) Crosswalk {
+	filtered := Crosswalk{sources: c.sources, versions: c.versions, lastModified: c.lastModified}
+	for _, entry := range c.Entries {
+		if checkID != "" && !contains(entry.Checks, checkID) {
+			continue
+		}
+		if id != "" && !strings.EqualFold(entry.ControlID, id) && !strings.EqualFold(entry.RequirementID, id) && !strings.EqualFold(entry.ReferenceID, id) {
+			continue
+		}
+		if reference != "" && entry.Reference != reference {
+			continue
+		}
+		filtered.Entries = append(filtered.Entries, entry)
+	}
+	return filtered
+}
+
+// WriteTable writes the crosswalk as an aligned text table.
+func (c Crosswalk) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SOURCE\tCONTROL\tREQUIREMENT\tREFERENCE\tREFERENCE ID\tCHECKS")
+	for _, entry := range c.Entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.Source, entry.ControlID, entry.RequirementID, entry.Reference, entry.ReferenceID, strings.Join(entry.Checks, ","))
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes the crosswalk as CSV with a header row.
+func (c Crosswalk) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"source", "control-id", "requirement-id", "reference", "reference-id", "strength", "checks"}); err != nil {
+		return err
+	}
+	for _, entry := range c.Entries {
+		record := []string{
+			entry.Source,
+			entry.ControlID,
+			entry.RequirementID,
+			entry.Reference,
+			entry.ReferenceID,
+			strconv.FormatInt(entry.Strength, 10),
+			strings.Join(entry.Checks, " "),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// MappingCollection is the OSCAL Mapping model root.
+type MappingCollection struct {
+	UUID     string              `json:"uuid"`
+	Metadata oscalTypes.Metadata `json:"metadata"`
+	Mappings []Mapping           `json:"mappings"`
+}
+
+// Mapping relates the controls of a source resource to a target resource.
+type Mapping struct {
+	UUID           string          `json:"uuid"`
+	SourceResource MappingResource `json:"source-resource"`
+	TargetResource MappingResource `json:"target-resource"`
+	Maps           []MappingEntry  `json:"maps"`
+}
+
+type MappingResource struct {
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+	Href  string `json:"href,omitempty"`
+}
+
+type MappingEntry struct {
+	UUID         string                 `json:"uuid"`
+	Relationship string                 `json:"relationship"`
+	Sources      []MappingItem          `json:"sources"`
+	Targets      []MappingItem          `json:"targets"`
+	Props        *[]oscalTypes.Property `json:"props,omitempty"`
+}
+
+type MappingItem struct {
+	Type  string `json:"type"`
+	IdRef string `json:"id-ref"`
+}
+
+// MappingCollection converts the crosswalk into an OSCAL Mapping collection with
+// one mapping per source and reference pair. UUIDs are derived from the mapped
+// controls and the catalog versions, so the same catalogs always produce the same
+// collection. The version and last-modified time come from the loaded catalogs;
+// the current time is used when no catalog declares when it was last modified.
+func (c Crosswalk) MappingCollection() MappingCollection {
+	type pair struct{ source, reference string }
+	mappings := make(map[pair]*Mapping)
+	var order []pair
+	seeds := make(map[string]int)
+	var entryUUIDs []string
+	for _, entry := range c.Entries {
+		key := pair{entry.Source, entry.Reference}
+		mapping, ok := mappings[key]
+		if !ok {
+			mapping = &Mapping{
+				UUID:           uuid.NewUUIDWithSource(fmt.Sprintf("%s@%s/%s", entry.Source, c.versions[entry.Source], entry.Reference)),
+				SourceResource: MappingResource{Type: "catalog", Title: entry.Source, Href: c.sources[entry.Source]},
+				TargetResource: MappingResource{Type: "catalog", Title: entry.Reference, Href: c.sources[entry.Reference]},
+			}
+			mappings[key] = mapping
+			order = append(order, key)
+		}
+
+		sourceID := entry.RequirementID
+		if sourceID == "" {
+			sourceID = entry.ControlID
+		}
+		var props []oscalTypes.Property
+		if entry.Strength > 0 {
+			props = append(props, oscalTypes.Property{Name: strengthProp, Value: strconv.FormatInt(entry.Strength, 10), Ns: ProvenanceNamespace})
+		}
+		for _, check := range entry.Checks {
+			props = append(props, oscalTypes.Property{Name: "check-id", Value: check, Ns: ProvenanceNamespace})
+		}
+		// Identical entries are numbered so each still gets a unique UUID.
+		seed := fmt.Sprintf("%s@%s/%s/%s/%s", entry.Source, c.versions[entry.Source], entry.Reference, sourceID, entry.ReferenceID)
+		if n := seeds[seed]; n > 0 {
+			seeds[seed]++
+			seed = fmt.Sprintf("%s#%d", seed, n)
+		} else {
+			seeds[seed] = 1
+		}
+		mapEntry := MappingEntry{
+			UUID:         uuid.NewUUIDWithSource(seed),
+			Relationship: "intersects-with",
+			Sources:      []MappingItem{{Type: "control", IdRef: sourceID}},
+			Targets:      []MappingItem{{Type: "control", IdRef: entry.ReferenceID}},
+		}
+		if len(props) > 0 {
+			mapEntry.Props = &props
+		}
+		mapping.Maps = append(mapping.Maps, mapEntry)
+		entryUUIDs = append(entryUUIDs, mapEntry.UUID)
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].source != order[j].source {
+			return order[i].source < order[j].source
+		}
+		return order[i].reference < order[j].reference
+	})
+
+	lastModified := c.lastModified
+	if lastModified.IsZero() {
+		lastModified = time.Now()
+	}
+	collection := MappingCollection{
+		UUID: uuid.NewUUIDWithSource(strings.Join(entryUUIDs, ",")),
+		Metadata: oscalTypes.Metadata{
+			Title:        "Crosswalk",
+			LastModified: lastModified,
+			OscalVersion: mappingOSCALVersion,
+			Version:      c.version(),
+		},
+		Mappings: make([]Mapping, 0, len(order)),
+	}
+	for _, key := range order {
+		collection.Mappings = append(collection.Mappings, *mappings[key])
+	}
+	return collection
+}
+
+// version returns the version of the only loaded catalog, or each catalog ID and
+// version when the crosswalk spans several catalogs.
+func (c Crosswalk) version() string {
+	var ids []string
+	for id, version := range c.versions {
+		if version != "" {
+			ids = append(ids, id)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return defaultMappingVersion
+	case 1:
+		if len(c.versions) == 1 {
+			return c.versions[ids[0]]
+		}
+	}
+	sort.Strings(ids)
+	versions := make([]string, 0, len(ids))
+	for _, id := range ids {
+		versions = append(versions, fmt.Sprintf("%s %s", id, c.versions[id]))
+	}
+	return strings.Join(versions, ", ")
+}
+
+func writeJSON(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// extraCatalog maps to 800-53 and to a second reference, with mapping strengths and
+// a control without assessment requirements.
+const extraCatalog = `metadata:
+  id: EXTRA
+  title: Extra Catalog
+  description: Extra controls
+  version: "2.0"
+  last-modified: "2026-01-02T03:04:05Z"
+  mapping-references:
+    - id: 800-53
+      title: Ignored in favor of the first catalog's reference
+      version: r5
+      url: https://example.com/800-53
+    - id: CCM
+      title: Cloud Controls Matrix
+      version: "4.0"
+      url: https://example.com/ccm
+control-families:
+  - id: EXT
+    title: Extra
+    description: Extra controls
+    controls:
+      - id: EXT-01
+        title: Review changes
+        objective: Review changes
+        guideline-mappings:
+          - reference-id: 800-53
+            entries:
+              - reference-id: PL-1
+                strength: 8
+          - reference-id: CCM
+            entries:
+              - reference-id: CCC-01
+                strength: 10
+        assessment-requirements:
+          - id: EXT-01.01
+            text: Changes are reviewed
+          - id: EXT-01.02
+            text: Reviews are recorded
+      - id: EXT-02
+        title: No requirements
+        objective: No requirements
+        guideline-mappings:
+          - reference-id: CCM
+            entries:
+              - reference-id: CCC-02
+`
+
+func testCrosswalk(t *testing.T) Crosswalk {
+	t.Helper()
+	extraPath := filepath.Join(t.TempDir(), "extra.yaml")
+	if err := os.WriteFile(extraPath, []byte(extraCatalog), 0600); err != nil {
+		t.Fatal(err)
+	}
+	crosswalk, err := buildCrosswalk(
+		[]string{filepath.Join(governance, "catalogs", "cnscc.yaml"), extraPath},
+		[]string{filepath.Join(governance, "plans", "cnscc.yaml")},
+	)
+	if err != nil {
+		t.Fatalf("buildCrosswalk() error = %v", err)
+	}
+	return crosswalk
+}
+
+func TestBuildCrosswalk(t *testing.T) {
+	crosswalk := testCrosswalk(t)
+
+	bySource := make(map[string][]CrosswalkEntry)
+	for _, entry := range crosswalk.Entries {
+		bySource[entry.Source] = append(bySource[entry.Source], entry)
+	}
+	if got := len(bySource["CNSCC"]); got != 46 {
+		t.Errorf("buildCrosswalk() has %d CNSCC entries, want 46", got)
+	}
+	wantExtra := []CrosswalkEntry{
+		{Source: "EXTRA", ControlID: "EXT-01", RequirementID: "EXT-01.01", Reference: "800-53", ReferenceID: "PL-1", Strength: 8},
+		{Source: "EXTRA", ControlID: "EXT-01", RequirementID: "EXT-01.02", Reference: "800-53", ReferenceID: "PL-1", Strength: 8},
+		{Source: "EXTRA", ControlID: "EXT-01", RequirementID: "EXT-01.01", Reference: "CCM", ReferenceID: "CCC-01", Strength: 10},
+		{Source: "EXTRA", ControlID: "EXT-01", RequirementID: "EXT-01.02", Reference: "CCM", ReferenceID: "CCC-01", Strength: 10},
+		{Source: "EXTRA", ControlID: "EXT-02", Reference: "CCM", ReferenceID: "CCC-02"},
+	}
+	if !reflect.DeepEqual(bySource["EXTRA"], wantExtra) {
+		t.Errorf("buildCrosswalk() EXTRA entries = %+v, want %+v", bySource["EXTRA"], wantExtra)
+	}
+
+	wantSources := map[string]string{
+		"CNSCC":  filepath.Join(governance, "catalogs", "cnscc.yaml"),
+		"EXTRA":  crosswalk.sources["EXTRA"],
+		"800-53": "https://nvlpubs.nist.gov/nistpubs/SpecialPublications/NIST.SP.800-53r5.pdf",
+		"CCM":    "https://example.com/ccm",
+	}
+	if !reflect.DeepEqual(crosswalk.sources, wantSources) {
+		t.Errorf("buildCrosswalk() sources = %v, want %v", crosswalk.sources, wantSources)
+	}
+	if _, err := buildCrosswalk([]string{"missing.yaml"}, nil); err == nil {
+		t.Error("buildCrosswalk() with a missing catalog succeeded, want an error")
+	}
+}
+
+func TestCrosswalkFilter(t *testing.T) {
+	crosswalk := testCrosswalk(t)
+
+	type row struct{ source, reference string }
+	tests := []struct {
+		name      string
+		checkID   string
+		id        string
+		reference string
+		want      []row
+	}{
+		{
+			name:    "check",
+			checkID: "github_branch_protection",
+			want:    []row{{"CNSCC-SSC-09.01", "SA-11(4)"}},
+		},
+		{
+			name: "source control",
+			id:   "cnscc-ssc-09",
+			want: []row{{"CNSCC-SSC-09.01", "SA-11(4)"}},
+		},
+		{
+			name: "source requirement",
+			id:   "EXT-01.02",
+			want: []row{{"EXT-01.02", "PL-1"}, {"EXT-01.02", "CCC-01"}},
+		},
+		{
+			name: "reference control",
+			id:   "PL-1",
+			want: []row{
+				{"CNSCC-SSC-05.01", "PL-1"},
+				{"CNSCC-SSC-07.01", "PL-1"},
+				{"CNSCC-SSC-08.01", "PL-1"},
+				{"EXT-01.01", "PL-1"},
+				{"EXT-01.02", "PL-1"},
+			},
+		},
+		{
+			name:      "reference control and reference",
+			id:        "ccc-02",
+			reference: "CCM",
+			want:      []row{{"EXT-02", "CCC-02"}},
+		},
+		{
+			name:      "reference",
+			reference: "CCM",
+			want:      []row{{"EXT-01.01", "CCC-01"}, {"EXT-01.02", "CCC-01"}, {"EXT-02", "CCC-02"}},
+		},
+		{
+			name:      "check and reference",
+			checkID:   "github_branch_protection",
+			reference: "CCM",
+		},
+		{
+			name: "unknown id",
+			id:   "XX-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered := crosswalk.Filter(tt.checkID, tt.id, tt.reference)
+			var got []row
+			for _, entry := range filtered.Entries {
+				source := entry.RequirementID
+				if source == "" {
+					source = entry.ControlID
+				}
+				got = append(got, row{source, entry.ReferenceID})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(filtered.sources, crosswalk.sources) || !reflect.DeepEqual(filtered.versions, crosswalk.versions) {
+				t.Errorf("Filter() dropped the catalog sources or versions")
+			}
+		})
+	}
+}
+
+func TestMappingCollection(t *testing.T) {
+	crosswalk := testCrosswalk(t)
+	collection := crosswalk.MappingCollection()
+
+	if again := crosswalk.MappingCollection(); !reflect.DeepEqual(again, collection) {
+		t.Error("MappingCollection() returned a different collection for the same crosswalk")
+	}
+	// UUIDs depend on the catalog contents, not on where the catalogs were loaded from.
+	if other := testCrosswalk(t).MappingCollection(); other.UUID != collection.UUID {
+		t.Errorf("MappingCollection() UUID = %s for the same catalogs, want %s", other.UUID, collection.UUID)
+	}
+	if want := "CNSCC 1.0, EXTRA 2.0"; collection.Metadata.Version != want {
+		t.Errorf("MappingCollection() version = %q, want %q", collection.Metadata.Version, want)
+	}
+	if want := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC); !collection.Metadata.LastModified.Equal(want) {
+		t.Errorf("MappingCollection() last-modified = %v, want %v", collection.Metadata.LastModified, want)
+	}
+
+	uuids := map[string]bool{collection.UUID: true}
+	strengths := make(map[string]string)
+	for _, mapping := range collection.Mappings {
+		uuids[mapping.UUID] = true
+		for _, entry := range mapping.Maps {
+			uuids[entry.UUID] = true
+			if entry.Props == nil {
+				continue
+			}
+			for _, prop := range *entry.Props {
+				if prop.Name == strengthProp {
+					strengths[entry.Sources[0].IdRef+" "+entry.Targets[0].IdRef] = prop.Value
+				}
+			}
+		}
+	}
+	if want := 1 + len(collection.Mappings) + len(crosswalk.Entries); len(uuids) != want {
+		t.Errorf("MappingCollection() has %d unique UUIDs, want %d", len(uuids), want)
+	}
+	wantStrengths := map[string]string{
+		"EXT-01.01 PL-1":   "8",
+		"EXT-01.02 PL-1":   "8",
+		"EXT-01.01 CCC-01": "10",
+		"EXT-01.02 CCC-01": "10",
+	}
+	if !reflect.DeepEqual(strengths, wantStrengths) {
+		t.Errorf("MappingCollection() strengths = %v, want %v", strengths, wantStrengths)
+	}
+
+	filtered := crosswalk.Filter("", "", "CCM").MappingCollection()
+	if filtered.UUID == collection.UUID {
+		t.Error("MappingCollection() of a filtered crosswalk reused the full collection UUID")
+	}
+	if len(filtered.Mappings) != 1 || filtered.Mappings[0].UUID != collection.Mappings[len(collection.Mappings)-1].UUID {
+		t.Errorf("MappingCollection() of a filtered crosswalk changed the EXTRA to CCM mapping UUID")
+	}
+
+	single := Crosswalk{versions: map[string]string{"CNSCC": "1.0"}}
+	if got := single.version(); got != "1.0" {
+		t.Errorf("version() of one catalog = %q, want 1.0", got)
+	}
+	if got := (Crosswalk{versions: map[string]string{"CNSCC": ""}}).version(); got != defaultMappingVersion {
+		t.Errorf("version() without catalog versions = %q, want %s", got, defaultMappingVersion)
+	}
+}
@@ -0,0 +1,265 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	oscalTypes "github.com/defenseunicorns/go-oscal/src/types/oscal-1-1-3"
+	"github.com/goccy/go-yaml"
+	"github.com/oscal-compass/oscal-sdk-go/extensions"
+	"github.com/ossf/gemara/layer4"
+	"github.com/spf13/cobra"
+)
+
+// importedComponent is the subset of an OSCAL component needed to recover
+// Gemara Layer 4 procedures.
+type importedComponent struct {
+	Title                  string
+	Type                   string
+	Props                  []oscalTypes.Property
+	ControlImplementations []oscalTypes.ControlImplementationSet
+}
+
+// importedCheck is a single check recovered from a rule set group of trestle properties.
+type importedCheck struct {
+	RuleID      string
+	CheckID     string
+	Description string
+	// Name is the original procedure name, if the plan recorded one.
+	Name string
+}
+
+func NewImportCompDefCommand() *cobra.Command {
+	var catalogPath, outputPath, planID, planVersion, evaluator string
+
+	command := &cobra.Command{
+		Use:   "import-compdef <oscal-json>",
+		Short: "Import an OSCAL Component Definition into a Gemara Layer 4 Evaluation Plan",
+		Long: `Reads Rule_Id, Check_Id, and Check_Description properties from the validation
+components of an OSCAL Component Definition (or the assessment assets of an OSCAL Assessment Plan)
+and writes the equivalent Gemara Layer 4 Evaluation Plan YAML. Procedure names and the evaluator URI
+are restored from the properties the plan command records, and otherwise default to the check ID.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			components, err := loadOSCALComponents(args[0])
+			if err != nil {
+				return err
+			}
+
+			controlLookup := make(map[string]string)
+			if catalogPath != "" {
+				catalog, _, err := loadCatalog(catalogPath)
+				if err != nil {
+					return err
+				}
+				for _, family := range catalog.ControlFamilies {
+					for _, control := range family.Controls {
+						for _, requirement := range control.AssessmentRequirements {
+							controlLookup[requirement.Id] = control.Id
+						}
+					}
+				}
+			}
+
+			plan, err := componentsToEvaluationPlan(components, controlLookup)
+			if err != nil {
+				return err
+			}
+			plan.Metadata.Id = planID
+			plan.Metadata.Version = planVersion
+			if evaluator != "" {
+				plan.Metadata.Evaluator.Name = evaluator
+			}
+
+			planData, err := yaml.Marshal(plan)
+			if err != nil {
+				return err
+			}
+			if outputPath == "" {
+				_, err = cmd.OutOrStdout().Write(planData)
+				return err
+			}
+			return os.WriteFile(filepath.Clean(outputPath), planData, 0600)
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVarP(&catalogPath, "catalog-path", "c", "", "Optional path to a L2 Catalog used to resolve requirement IDs to control IDs")
+	flags.StringVarP(&outputPath, "output", "o", "", "Path to write the Layer 4 Evaluation Plan (defaults to stdout)")
+	flags.StringVar(&planID, "id", "imported-plan", "Identifier for the generated Evaluation Plan")
+	flags.StringVar(&planVersion, "version", "0.1.0", "Version for the generated Evaluation Plan")
+	flags.StringVar(&evaluator, "evaluator", "", "Evaluator name (defaults to the validation component title)")
+	return command
+}
+
+// loadOSCALComponents reads the components from an OSCAL Component Definition or
+// the assessment assets of an OSCAL Assessment Plan.
+func loadOSCALComponents(path string) ([]importedComponent, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	var models oscalTypes.OscalModels
+	if err := json.Unmarshal(data, &models); err != nil {
+		return nil, fmt.Errorf("error decoding OSCAL document %s: %w", path, err)
+	}
+
+	var components []importedComponent
+	switch {
+	case models.ComponentDefinition != nil:
+		if models.ComponentDefinition.Components == nil {
+			break
+		}
+		for _, comp := range *models.ComponentDefinition.Components {
+			imported := importedComponent{Title: comp.Title, Type: comp.Type}
+			if comp.Props != nil {
+				imported.Props = *comp.Props
+			}
+			if comp.ControlImplementations != nil {
+				imported.ControlImplementations = *comp.ControlImplementations
+			}
+			components = append(components, imported)
+		}
+	case models.AssessmentPlan != nil:
+		assets := models.AssessmentPlan.AssessmentAssets
+		if assets == nil || assets.Components == nil {
+			break
+		}
+		for _, comp := range *assets.Components {
+			imported := importedComponent{Title: comp.Title, Type: comp.Type}
+			if comp.Props != nil {
+				imported.Props = *comp.Props
+			}
+			components = append(components, imported)
+		}
+	default:
+		return nil, fmt.Errorf("%s does not contain an OSCAL Component Definition or Assessment Plan", path)
+	}
+	return components, nil
+}
+
+// componentsToEvaluationPlan groups the checks declared on validation components by
+// control and requirement. Control IDs are resolved with controlLookup first, then
+// with the implemented requirements of the target components, and finally fall back
+// to the requirement ID.
+func componentsToEvaluationPlan(components []importedComponent, controlLookup map[string]string) (layer4.EvaluationPlan, error) {
+	var plan layer4.EvaluationPlan
+
+	implementedControls := make(map[string]string)
+	for _, comp := range components {
+		for _, ci := range comp.ControlImplementations {
+			for _, ir := range ci.ImplementedRequirements {
+				if ir.Props == nil {
+					continue
+				}
+				for _, prop := range extensions.FindAllProps(*ir.Props, extensions.WithName(extensions.RuleIdProp)) {
+					if _, ok := implementedControls[prop.Value]; !ok {
+						implementedControls[prop.Value] = ir.ControlId
+					}
+				}
+			}
+		}
+	}
+
+	planIndex := make(map[string]int)
+	assessmentIndex := make(map[string]map[string]int)
+	for _, comp := range components {
+		if comp.Type != "validation" {
+			continue
+		}
+		if plan.Metadata.Evaluator.Name == "" {
+			plan.Metadata.Evaluator.Name = comp.Title
+		}
+		if plan.Metadata.Evaluator.URI == "" {
+			plan.Metadata.Evaluator.URI = gemaraProp(comp.Props, evaluatorURIProp, "")
+		}
+
+		for _, check := range groupChecks(comp.Props) {
+			controlID, ok := controlLookup[check.RuleID]
+			if !ok {
+				controlID, ok = implementedControls[check.RuleID]
+			}
+			if !ok {
+				controlID = check.RuleID
+			}
+
+			pIdx, ok := planIndex[controlID]
+			if !ok {
+				pIdx = len(plan.Plans)
+				planIndex[controlID] = pIdx
+				assessmentIndex[controlID] = make(map[string]int)
+				plan.Plans = append(plan.Plans, layer4.AssessmentPlan{ControlId: controlID})
+			}
+			aIdx, ok := assessmentIndex[controlID][check.RuleID]
+			if !ok {
+				aIdx = len(plan.Plans[pIdx].Assessments)
+				assessmentIndex[controlID][check.RuleID] = aIdx
+				plan.Plans[pIdx].Assessments = append(plan.Plans[pIdx].Assessments, layer4.Assessment{RequirementId: check.RuleID})
+			}
+
+			name := check.Name
+			if name == "" {
+				name = check.CheckID
+			}
+			assessment := &plan.Plans[pIdx].Assessments[aIdx]
+			assessment.Procedures = append(assessment.Procedures, layer4.AssessmentProcedure{
+				Id:          check.CheckID,
+				Name:        name,
+				Description: check.Description,
+			})
+		}
+	}
+
+	if len(plan.Plans) == 0 {
+		return plan, errors.New("no Rule_Id and Check_Id properties found on validation components")
+	}
+	return plan, nil
+}
+
+// groupChecks pairs Rule_Id, Check_Id, and Check_Description properties that share
+// the same rule set remarks, along with the procedure name recorded for the rule set.
+func groupChecks(props []oscalTypes.Property) []importedCheck {
+	var checks []importedCheck
+	groups := make(map[string]int)
+	for _, prop := range extensions.FindAllProps(props) {
+		idx, ok := groups[prop.Remarks]
+		if !ok {
+			idx = len(checks)
+			groups[prop.Remarks] = idx
+			checks = append(checks, importedCheck{})
+		}
+		switch prop.Name {
+		case extensions.RuleIdProp:
+			checks[idx].RuleID = prop.Value
+		case extensions.CheckIdProp:
+			checks[idx].CheckID = prop.Value
+		case extensions.CheckDescriptionProp:
+			checks[idx].Description = prop.Value
+		}
+	}
+
+	for remarks, idx := range groups {
+		checks[idx].Name = gemaraProp(props, procedureNameProp, remarks)
+	}
+
+	complete := checks[:0]
+	for _, check := range checks {
+		if check.RuleID != "" && check.CheckID != "" {
+			complete = append(complete, check)
+		}
+	}
+	return complete
+}
+
+// gemaraProp returns the value of the named Gemara property with the given remarks.
+func gemaraProp(props []oscalTypes.Property, name, remarks string) string {
+	for _, prop := range props {
+		if prop.Ns == GemaraNamespace && prop.Name == name && prop.Remarks == remarks {
+			return prop.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/ossf/gemara/layer4"
+)
+
+func TestImportCompDefRoundTrip(t *testing.T) {
+	target := governanceTarget("target", "")
+	result, err := buildAssessmentPlan(context.Background(), target.planOptions())
+	if err != nil {
+		t.Fatalf("buildAssessmentPlan() error = %v", err)
+	}
+	data, err := json.Marshal(result.Models)
+	if err != nil {
+		t.Fatal(err)
+	}
+	planPath := filepath.Join(t.TempDir(), "assessment-plan.json")
+	if err := os.WriteFile(planPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	planFile := filepath.Join(governance, "plans", "cnscc.yaml")
+	original, _, err := loadEvaluationPlan(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	command := NewImportCompDefCommand()
+	command.SetOut(&out)
+	command.SetArgs([]string{planPath, "--catalog-path", target.Catalogs[0], "--id", original.Metadata.Id, "--version", original.Metadata.Version})
+	if err := command.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	var imported layer4.EvaluationPlan
+	if err := yaml.Unmarshal(out.Bytes(), &imported); err != nil {
+		t.Fatalf("imported plan: %v", err)
+	}
+	if !reflect.DeepEqual(imported, original) {
+		t.Errorf("imported plan = %+v, want %s as %+v", imported, planFile, original)
+	}
+}
+
+func TestGroupChecksProcedureName(t *testing.T) {
+	target := governanceTarget("target", "")
+	result, err := buildAssessmentPlan(context.Background(), target.planOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, comp := range *result.Models.AssessmentPlan.AssessmentAssets.Components {
+		if comp.Type != "validation" {
+			continue
+		}
+		// Without the Gemara properties, procedures fall back to their check IDs.
+		var trestleProps = (*comp.Props)[:0:0]
+		for _, prop := range *comp.Props {
+			if prop.Ns != GemaraNamespace {
+				trestleProps = append(trestleProps, prop)
+			}
+		}
+		for _, check := range groupChecks(trestleProps) {
+			if check.Name != "" {
+				t.Errorf("groupChecks() name = %q without Gemara properties, want none", check.Name)
+			}
+		}
+		for _, check := range groupChecks(*comp.Props) {
+			if check.Name == "" || check.Name == check.CheckID {
+				t.Errorf("groupChecks() name of %s = %q, want the procedure name", check.CheckID, check.Name)
+			}
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/complytime/gemara2oscal/component"
 	oscalTypes "github.com/defenseunicorns/go-oscal/src/types/oscal-1-1-3"
@@ -16,79 +18,73 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// planOptions describe the Gemara inputs used to build a single
+// OSCAL Assessment Plan.
+type planOptions struct {
+	CatalogPaths     []string
+	EvaluationPaths  []string
+	TargetComponent  string
+	ComponentType    string
+	ComponentVersion string
+	PolicyPath       string
+	GuidanceRef      string
+}
+
+const (
+	// defaultComponentVersion is the component definition version used when one is not set.
+	defaultComponentVersion = "0.1.0"
+	// defaultDefinitionTitle is the component definition title used when no target component is named.
+	defaultDefinitionTitle = "GitHub Repository"
+
+	// GemaraNamespace is the OSCAL property namespace for Layer 4 fields that the trestle
+	// check properties do not carry, so import-compdef can restore them.
+	GemaraNamespace = "https://github.com/jpower432/opensource-securitycon-2025-oscal-in-action/gemara"
+	// procedureNameProp holds the name of the procedure behind a check, grouped with the
+	// check properties by their rule set remarks.
+	procedureNameProp = "procedure-name"
+	// evaluatorURIProp holds the URI of the evaluator of a validation component.
+	evaluatorURIProp = "evaluator-uri"
+)
+
+// planResult is a generated OSCAL Assessment Plan along with the
+// inputs that produced it.
+type planResult struct {
+	Models oscalTypes.OscalModels
+	Inputs []artifactInput
+}
+
 func NewPlanCommand() *cobra.Command {
-	var catalogPath, targetComponent, componentType, evaluationsPath, policyPath, guidanceRef string
+	var catalogPath, targetComponent, componentType, componentVersion, evaluationsPath, policyPath, guidanceRef, provenancePath string
 
 	command := &cobra.Command{
 		Use:   "plan",
 		Short: "Transform Gemara governance artifacts to an OSCAL Assessment Plan",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			builder := component.NewDefinitionBuilder("GitHub Repository", "0.1.0")
-
-			cleanedCatalogPath := filepath.Clean(catalogPath)
-			catalogData, err := os.ReadFile(cleanedCatalogPath)
-			if err != nil {
-				return err
+			opts := planOptions{
+				CatalogPaths:     []string{catalogPath},
+				EvaluationPaths:  []string{evaluationsPath},
+				TargetComponent:  targetComponent,
+				ComponentType:    componentType,
+				ComponentVersion: componentVersion,
+				PolicyPath:       policyPath,
+				GuidanceRef:      guidanceRef,
 			}
-
-			var layer2Catalog layer2.Catalog
-			if err := layer2Catalog.LoadFile(fmt.Sprintf("file://%s", cleanedCatalogPath)); err != nil {
-				return err
-			}
-			err = yaml.Unmarshal(catalogData, &layer2Catalog)
+			start := time.Now()
+			result, err := buildAssessmentPlan(cmd.Context(), opts)
 			if err != nil {
 				return err
 			}
-
-			cleanedPlanPath := filepath.Clean(evaluationsPath)
-			planBytes, err := os.ReadFile(cleanedPlanPath)
+			compDefData, err := json.MarshalIndent(result.Models, "", " ")
 			if err != nil {
 				return err
 			}
-			var layer4Plan layer4.EvaluationPlan
-			err = yaml.Unmarshal(planBytes, &layer4Plan)
-			if err != nil {
-				return err
-			}
-
-			builder = builder.AddTargetComponent(targetComponent, componentType, layer2Catalog)
-			builder = builder.AddValidationComponent(layer4Plan)
-
-			cleanedPolicyPath := filepath.Clean(policyPath)
-			var layer3Policy layer3.PolicyDocument
-			if err := layer3Policy.LoadFile(fmt.Sprintf("file://%s", cleanedPolicyPath)); err != nil {
-				return err
-			}
-
-			for _, ref := range layer3Policy.ControlReferences {
-				builder = builder.AddParameterModifiers(ref.ReferenceId, ref.ParameterModifications)
-			}
-			compDef := builder.Build()
-
-			var found bool
-			for _, guidance := range layer3Policy.GuidanceReferences {
-				if guidanceRef == guidance.ReferenceId {
-					ap, err := transformers.ComponentDefinitionsToAssessmentPlan(cmd.Context(), []oscalTypes.ComponentDefinition{compDef}, guidance.ReferenceId)
-					if err != nil {
-						return err
-					}
-					oscalModels := oscalTypes.OscalModels{
-						AssessmentPlan: ap,
-					}
-					compDefData, err := json.MarshalIndent(oscalModels, "", " ")
-					if err != nil {
-						return err
-					}
-					_, _ = fmt.Fprintln(os.Stdout, string(compDefData))
-					found = true
-					break
-				}
-			}
+			compDefData = append(compDefData, '\n')
+			_, _ = os.Stdout.Write(compDefData)
 
-			if !found {
-				return fmt.Errorf("guidance reference %q does not exist in policy", guidanceRef)
+			if provenancePath != "" {
+				statement := newProvenanceStatement("assessment-plan.json", compDefData, opts, result.Inputs, start)
+				return writeProvenance(provenancePath, statement)
 			}
-
 			return nil
 		},
 	}
@@ -98,7 +94,209 @@ func NewPlanCommand() *cobra.Command {
 	flags.StringVarP(&evaluationsPath, "evaluation-path", "e", "./governance/plans/cnscc.yaml", "Path to Layer 4 Evaluation Plan to transform")
 	flags.StringVarP(&targetComponent, "target-component", "t", "", "Title for target component for evaluation")
 	flags.StringVar(&componentType, "component-type", "software", "Component type (based on valid OSCAL component types)")
+	flags.StringVar(&componentVersion, "component-version", defaultComponentVersion, "Version of the component definition the plan is built from")
 	flags.StringVarP(&policyPath, "policy-path", "p", "./governance/policy.yaml", "Path to Layer 3 policy")
 	flags.StringVarP(&guidanceRef, "guidance-reference", "r", "", "Guidance reference to tailor the plan to")
+	flags.StringVar(&provenancePath, "provenance", "", "Optional path to write an in-toto provenance statement for the generated plan")
 	return command
 }
+
+// buildAssessmentPlan loads the Gemara artifacts described by opts and transforms
+// them into an OSCAL Assessment Plan tailored to the given guidance reference.
+// The plan metadata is stamped with the provenance of each input.
+func buildAssessmentPlan(ctx context.Context, opts planOptions) (planResult, error) {
+	title := opts.TargetComponent
+	if title == "" {
+		title = defaultDefinitionTitle
+	}
+	builder := component.NewDefinitionBuilder(title, opts.ComponentVersion)
+	var inputs []artifactInput
+
+	var catalogs []layer2.Catalog
+	for _, catalogPath := range opts.CatalogPaths {
+		layer2Catalog, input, err := loadCatalog(catalogPath)
+		if err != nil {
+			return planResult{}, err
+		}
+		inputs = append(inputs, input)
+		catalogs = append(catalogs, layer2Catalog)
+	}
+
+	var evaluationPlans []layer4.EvaluationPlan
+	for _, evaluationPath := range opts.EvaluationPaths {
+		layer4Plan, input, err := loadEvaluationPlan(evaluationPath)
+		if err != nil {
+			return planResult{}, err
+		}
+		inputs = append(inputs, input)
+		evaluationPlans = append(evaluationPlans, layer4Plan)
+		builder = builder.AddValidationComponent(layer4Plan)
+	}
+
+	layer3Policy, input, err := loadPolicy(opts.PolicyPath)
+	if err != nil {
+		return planResult{}, err
+	}
+	inputs = append(inputs, input)
+
+	// Only validation components are added to the builder, in evaluation plan order.
+	compDef := builder.Build()
+	var components []oscalTypes.DefinedComponent
+	if len(catalogs) > 0 {
+		components = append(components, targetComponent(opts, catalogs, layer3Policy))
+	}
+	if compDef.Components != nil {
+		for i, validation := range *compDef.Components {
+			addGemaraProps(&validation, evaluationPlans[i])
+			components = append(components, validation)
+		}
+	}
+	compDef.Components = &components
+
+	for _, guidance := range layer3Policy.GuidanceReferences {
+		if opts.GuidanceRef == guidance.ReferenceId {
+			ap, err := transformers.ComponentDefinitionsToAssessmentPlan(ctx, []oscalTypes.ComponentDefinition{compDef}, guidance.ReferenceId)
+			if err != nil {
+				return planResult{}, err
+			}
+			stampMetadata(&ap.Metadata, inputs, opts.PolicyPath)
+			return planResult{
+				Models: oscalTypes.OscalModels{
+					AssessmentPlan: ap,
+				},
+				Inputs: inputs,
+			}, nil
+		}
+	}
+
+	return planResult{}, fmt.Errorf("guidance reference %q does not exist in policy", opts.GuidanceRef)
+}
+
+// targetComponent builds the single target component for all catalogs with the policy
+// parameter modifications applied. The definition builder adds a component per catalog,
+// so each catalog is built on its own and its rules and control implementations are
+// attached to the first, renumbering rule sets so they stay unique across catalogs.
+func targetComponent(opts planOptions, catalogs []layer2.Catalog, policy layer3.PolicyDocument) oscalTypes.DefinedComponent {
+	var target oscalTypes.DefinedComponent
+	var props []oscalTypes.Property
+	var implementations []oscalTypes.ControlImplementationSet
+	var ruleSets int
+	for i, catalog := range catalogs {
+		builder := component.NewDefinitionBuilder(opts.TargetComponent, opts.ComponentVersion).
+			AddTargetComponent(opts.TargetComponent, opts.ComponentType, catalog)
+		for _, ref := range policy.ControlReferences {
+			builder = builder.AddParameterModifiers(ref.ReferenceId, ref.ParameterModifications)
+		}
+		built := (*builder.Build().Components)[0]
+		if i == 0 {
+			target = built
+		}
+
+		offset := ruleSets
+		if built.Props != nil {
+			for _, prop := range *built.Props {
+				var n int
+				if _, err := fmt.Sscanf(prop.Remarks, ruleSetRemarks, &n); err == nil {
+					prop.Remarks = fmt.Sprintf(ruleSetRemarks, n+offset)
+					ruleSets = max(ruleSets, n+offset+1)
+				}
+				props = append(props, prop)
+			}
+		}
+		if built.ControlImplementations != nil {
+			implementations = append(implementations, *built.ControlImplementations...)
+		}
+	}
+
+	target.Props, target.ControlImplementations = nil, nil
+	if len(props) > 0 {
+		target.Props = &props
+	}
+	if len(implementations) > 0 {
+		target.ControlImplementations = &implementations
+	}
+	return target
+}
+
+// addGemaraProps records the evaluator URI and procedure names of an evaluation plan on
+// its validation component. Procedures are numbered in the order the definition builder
+// numbers their rule sets.
+func addGemaraProps(validation *oscalTypes.DefinedComponent, plan layer4.EvaluationPlan) {
+	var props []oscalTypes.Property
+	if validation.Props != nil {
+		props = *validation.Props
+	}
+	if plan.Metadata.Evaluator.URI != "" {
+		props = append(props, oscalTypes.Property{Name: evaluatorURIProp, Value: plan.Metadata.Evaluator.URI, Ns: GemaraNamespace})
+	}
+	var group int
+	for _, assessmentPlan := range plan.Plans {
+		for _, assessment := range assessmentPlan.Assessments {
+			for _, procedure := range assessment.Procedures {
+				if procedure.Name != "" {
+					props = append(props, oscalTypes.Property{
+						Name:    procedureNameProp,
+						Value:   procedure.Name,
+						Ns:      GemaraNamespace,
+						Remarks: fmt.Sprintf(ruleSetRemarks, group),
+					})
+				}
+				group++
+			}
+		}
+	}
+	if len(props) > 0 {
+		validation.Props = &props
+	}
+}
+
+// ruleSetRemarks is the remarks format grouping the properties of one rule.
+const ruleSetRemarks = "rule_set_%d"
+
+func loadCatalog(catalogPath string) (layer2.Catalog, artifactInput, error) {
+	cleanedCatalogPath := filepath.Clean(catalogPath)
+	catalogData, err := os.ReadFile(cleanedCatalogPath)
+	if err != nil {
+		return layer2.Catalog{}, artifactInput{}, err
+	}
+
+	var layer2Catalog layer2.Catalog
+	if err := layer2Catalog.LoadFile(fmt.Sprintf("file://%s", cleanedCatalogPath)); err != nil {
+		return layer2.Catalog{}, artifactInput{}, err
+	}
+	err = yaml.Unmarshal(catalogData, &layer2Catalog)
+	if err != nil {
+		return layer2.Catalog{}, artifactInput{}, err
+	}
+	input := newArtifactInput(inputCatalog, cleanedCatalogPath, layer2Catalog.Metadata.Id, layer2Catalog.Metadata.Version, catalogData)
+	return layer2Catalog, input, nil
+}
+
+func loadEvaluationPlan(evaluationPath string) (layer4.EvaluationPlan, artifactInput, error) {
+	cleanedPlanPath := filepath.Clean(evaluationPath)
+	planBytes, err := os.ReadFile(cleanedPlanPath)
+	if err != nil {
+		return layer4.EvaluationPlan{}, artifactInput{}, err
+	}
+	var layer4Plan layer4.EvaluationPlan
+	err = yaml.Unmarshal(planBytes, &layer4Plan)
+	if err != nil {
+		return layer4.EvaluationPlan{}, artifactInput{}, err
+	}
+	input := newArtifactInput(inputEvaluationPlan, cleanedPlanPath, layer4Plan.Metadata.Id, layer4Plan.Metadata.Version, planBytes)
+	return layer4Plan, input, nil
+}
+
+func loadPolicy(policyPath string) (layer3.PolicyDocument, artifactInput, error) {
+	cleanedPolicyPath := filepath.Clean(policyPath)
+	policyData, err := os.ReadFile(cleanedPolicyPath)
+	if err != nil {
+		return layer3.PolicyDocument{}, artifactInput{}, err
+	}
+	var layer3Policy layer3.PolicyDocument
+	if err := layer3Policy.LoadFile(fmt.Sprintf("file://%s", cleanedPolicyPath)); err != nil {
+		return layer3.PolicyDocument{}, artifactInput{}, err
+	}
+	input := newArtifactInput(inputPolicy, cleanedPolicyPath, layer3Policy.Metadata.Id, layer3Policy.Metadata.Version, policyData)
+	return layer3Policy, input, nil
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	oscalTypes "github.com/defenseunicorns/go-oscal/src/types/oscal-1-1-3"
+	"github.com/ossf/gemara/layer2"
+)
+
+func TestTargetComponent(t *testing.T) {
+	// A second catalog is the repository catalog under another ID.
+	data, err := os.ReadFile(filepath.Join(governance, "catalogs", "cnscc.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := filepath.Join(t.TempDir(), "second.yaml")
+	if err := os.WriteFile(second, []byte(strings.Replace(string(data), "id: CNSCC\n", "id: SECOND\n", 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	target := governanceTarget("target", "")
+	opts := target.planOptions()
+	build := func(paths ...string) oscalTypes.DefinedComponent {
+		t.Helper()
+		catalogs := make([]layer2.Catalog, 0, len(paths))
+		for _, path := range paths {
+			catalog, _, err := loadCatalog(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			catalogs = append(catalogs, catalog)
+		}
+		policy, _, err := loadPolicy(opts.PolicyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return targetComponent(opts, catalogs, policy)
+	}
+
+	one := build(opts.CatalogPaths[0])
+	two := build(opts.CatalogPaths[0], second)
+
+	if two.Title != opts.TargetComponent || two.Type != opts.ComponentType {
+		t.Errorf("targetComponent() = %s (%s), want %s (%s)", two.Title, two.Type, opts.TargetComponent, opts.ComponentType)
+	}
+	if got, want := len(*two.ControlImplementations), 2*len(*one.ControlImplementations); got != want {
+		t.Errorf("targetComponent() has %d control implementations, want %d", got, want)
+	}
+
+	ruleSets := func(component oscalTypes.DefinedComponent) map[string]int {
+		sets := make(map[string]int)
+		for _, prop := range *component.Props {
+			sets[prop.Remarks]++
+		}
+		return sets
+	}
+	oneSets, twoSets := ruleSets(one), ruleSets(two)
+	if len(twoSets) != 2*len(oneSets) {
+		t.Errorf("targetComponent() has %d rule sets, want %d", len(twoSets), 2*len(oneSets))
+	}
+	for remarks, count := range oneSets {
+		if twoSets[remarks] != count {
+			t.Errorf("rule set %s has %d properties, want %d", remarks, twoSets[remarks], count)
+		}
+	}
+}
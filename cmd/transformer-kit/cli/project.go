@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+)
+
+// DefaultProjectFile is the project file name used when one is not specified.
+const DefaultProjectFile = "transformer-kit.yaml"
+
+// Project describes every artifact the transformer kit should build.
+type Project struct {
+	// Defaults are applied to any target that leaves a field unset.
+	Defaults Target `yaml:"defaults,omitempty"`
+	// Targets are the individual artifacts to build.
+	Targets []Target `yaml:"targets"`
+}
+
+// Target describes the inputs and output location for a single
+// OSCAL Assessment Plan.
+type Target struct {
+	Name              string   `yaml:"name"`
+	Title             string   `yaml:"title"`
+	ComponentType     string   `yaml:"component-type,omitempty"`
+	ComponentVersion  string   `yaml:"component-version,omitempty"`
+	Catalogs          []string `yaml:"catalogs,omitempty"`
+	Plans             []string `yaml:"plans,omitempty"`
+	Policy            string   `yaml:"policy,omitempty"`
+	GuidanceReference string   `yaml:"guidance-reference,omitempty"`
+	Output            string   `yaml:"output"`
+	// Provenance writes an in-toto provenance statement next to the output.
+	Provenance bool `yaml:"provenance,omitempty"`
+}
+
+// LoadProject reads a project file and applies defaults to each target.
+// Relative paths are resolved against the directory containing the project file.
+func LoadProject(path string) (*Project, error) {
+	cleanedPath := filepath.Clean(path)
+	data, err := os.ReadFile(cleanedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var project Project
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return nil, fmt.Errorf("error decoding project file %s: %w", cleanedPath, err)
+	}
+
+	baseDir := filepath.Dir(cleanedPath)
+	for i := range project.Targets {
+		target := &project.Targets[i]
+		target.applyDefaults(project.Defaults)
+		target.resolvePaths(baseDir)
+	}
+
+	return &project, project.Validate()
+}
+
+// Validate checks that every target has the fields needed to build a plan.
+func (p *Project) Validate() error {
+	var errs []error
+	if len(p.Targets) == 0 {
+		errs = append(errs, errors.New("project must declare at least one target"))
+	}
+
+	names := make(map[string]struct{}, len(p.Targets))
+	// Targets are written concurrently, so two targets must never share an output.
+	outputs := make(map[string]string, len(p.Targets))
+	for i, target := range p.Targets {
+		if target.Name == "" {
+			errs = append(errs, fmt.Errorf("target %d: name must be set", i))
+			continue
+		}
+		if _, ok := names[target.Name]; ok {
+			errs = append(errs, fmt.Errorf("target %q: declared more than once", target.Name))
+		}
+		names[target.Name] = struct{}{}
+
+		if target.Title == "" {
+			errs = append(errs, fmt.Errorf("target %q: title must be set", target.Name))
+		}
+		if len(target.Catalogs) == 0 {
+			errs = append(errs, fmt.Errorf("target %q: at least one catalog must be set", target.Name))
+		}
+		if len(target.Plans) == 0 {
+			errs = append(errs, fmt.Errorf("target %q: at least one plan must be set", target.Name))
+		}
+		if target.Policy == "" {
+			errs = append(errs, fmt.Errorf("target %q: policy must be set", target.Name))
+		}
+		if target.GuidanceReference == "" {
+			errs = append(errs, fmt.Errorf("target %q: guidance-reference must be set", target.Name))
+		}
+		if target.Output == "" {
+			errs = append(errs, fmt.Errorf("target %q: output must be set", target.Name))
+			continue
+		}
+		written := []string{filepath.Clean(target.Output)}
+		if target.Provenance {
+			written = append(written, provenancePath(written[0]))
+		}
+		for _, path := range written {
+			if other, ok := outputs[path]; ok {
+				errs = append(errs, fmt.Errorf("target %q: %s is also written by target %q", target.Name, path, other))
+				continue
+			}
+			outputs[path] = target.Name
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *Target) planOptions() planOptions {
+	return planOptions{
+		CatalogPaths:     t.Catalogs,
+		EvaluationPaths:  t.Plans,
+		TargetComponent:  t.Title,
+		ComponentType:    t.ComponentType,
+		ComponentVersion: t.ComponentVersion,
+		PolicyPath:       t.Policy,
+		GuidanceRef:      t.GuidanceReference,
+	}
+}
+
+func (t *Target) applyDefaults(defaults Target) {
+	if t.ComponentType == "" {
+		t.ComponentType = defaults.ComponentType
+	}
+	if t.ComponentType == "" {
+		t.ComponentType = "software"
+	}
+	if t.ComponentVersion == "" {
+		t.ComponentVersion = defaults.ComponentVersion
+	}
+	if t.ComponentVersion == "" {
+		t.ComponentVersion = defaultComponentVersion
+	}
+	if len(t.Catalogs) == 0 {
+		t.Catalogs = defaults.Catalogs
+	}
+	if len(t.Plans) == 0 {
+		t.Plans = defaults.Plans
+	}
+	if t.Policy == "" {
+		t.Policy = defaults.Policy
+	}
+	if t.GuidanceReference == "" {
+		t.GuidanceReference = defaults.GuidanceReference
+	}
+	t.Provenance = t.Provenance || defaults.Provenance
+}
+
+func (t *Target) resolvePaths(baseDir string) {
+	t.Catalogs = resolveAll(baseDir, t.Catalogs)
+	t.Plans = resolveAll(baseDir, t.Plans)
+	t.Policy = resolve(baseDir, t.Policy)
+	t.Output = resolve(baseDir, t.Output)
+}
+
+func resolveAll(baseDir string, paths []string) []string {
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		resolved = append(resolved, resolve(baseDir, path))
+	}
+	return resolved
+}
+
+func resolve(baseDir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadProject(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DefaultProjectFile)
+	project := `defaults:
+  component-type: service
+  catalogs: [./catalogs/a.yaml]
+  plans: [./plans/a.yaml]
+  policy: ./policy.yaml
+  guidance-reference: 800-53
+  provenance: true
+targets:
+  - name: inherited
+    title: Inherited
+    output: ./out/inherited.json
+  - name: overridden
+    title: Overridden
+    component-type: hardware
+    component-version: 2.0.0
+    catalogs: [/abs/catalog.yaml, ./catalogs/b.yaml]
+    guidance-reference: other
+    output: /abs/overridden.json
+`
+	if err := os.WriteFile(path, []byte(project), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadProject(path)
+	if err != nil {
+		t.Fatalf("LoadProject() error = %v", err)
+	}
+	want := []Target{
+		{
+			Name:              "inherited",
+			Title:             "Inherited",
+			ComponentType:     "service",
+			ComponentVersion:  defaultComponentVersion,
+			Catalogs:          []string{filepath.Join(dir, "catalogs", "a.yaml")},
+			Plans:             []string{filepath.Join(dir, "plans", "a.yaml")},
+			Policy:            filepath.Join(dir, "policy.yaml"),
+			GuidanceReference: "800-53",
+			Output:            filepath.Join(dir, "out", "inherited.json"),
+			Provenance:        true,
+		},
+		{
+			Name:              "overridden",
+			Title:             "Overridden",
+			ComponentType:     "hardware",
+			ComponentVersion:  "2.0.0",
+			Catalogs:          []string{"/abs/catalog.yaml", filepath.Join(dir, "catalogs", "b.yaml")},
+			Plans:             []string{filepath.Join(dir, "plans", "a.yaml")},
+			Policy:            filepath.Join(dir, "policy.yaml"),
+			GuidanceReference: "other",
+			Output:            "/abs/overridden.json",
+			Provenance:        true,
+		},
+	}
+	if !reflect.DeepEqual(got.Targets, want) {
+		t.Errorf("LoadProject() targets = %+v, want %+v", got.Targets, want)
+	}
+}
+
+func TestLoadProjectComponentTypeDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultProjectFile)
+	project := `targets:
+  - name: target
+    title: Target
+    catalogs: [catalog.yaml]
+    plans: [plan.yaml]
+    policy: policy.yaml
+    guidance-reference: 800-53
+    output: out.json
+`
+	if err := os.WriteFile(path, []byte(project), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadProject(path)
+	if err != nil {
+		t.Fatalf("LoadProject() error = %v", err)
+	}
+	if target := got.Targets[0]; target.ComponentType != "software" || target.ComponentVersion != defaultComponentVersion || target.Provenance {
+		t.Errorf("LoadProject() target = %+v, want component type software, version %s and no provenance", target, defaultComponentVersion)
+	}
+}
+
+func TestProjectValidate(t *testing.T) {
+	complete := func(name, output string) Target {
+		return Target{
+			Name:              name,
+			Title:             "Title",
+			Catalogs:          []string{"catalog.yaml"},
+			Plans:             []string{"plan.yaml"},
+			Policy:            "policy.yaml",
+			GuidanceReference: "800-53",
+			Output:            output,
+		}
+	}
+	tests := []struct {
+		name    string
+		targets []Target
+		wantErr []string
+	}{
+		{
+			name:    "valid targets",
+			targets: []Target{complete("a", "a.json"), complete("b", "b.json")},
+		},
+		{
+			name:    "no targets",
+			wantErr: []string{"at least one target"},
+		},
+		{
+			name:    "missing name",
+			targets: []Target{complete("", "a.json")},
+			wantErr: []string{"target 0: name must be set"},
+		},
+		{
+			name:    "duplicate name",
+			targets: []Target{complete("a", "a.json"), complete("a", "b.json")},
+			wantErr: []string{`target "a": declared more than once`},
+		},
+		{
+			name: "missing catalogs and plans",
+			targets: []Target{func() Target {
+				target := complete("a", "a.json")
+				target.Catalogs, target.Plans = nil, nil
+				return target
+			}()},
+			wantErr: []string{`target "a": at least one catalog must be set`, `target "a": at least one plan must be set`},
+		},
+		{
+			name:    "missing fields",
+			targets: []Target{{Name: "a"}},
+			wantErr: []string{"title must be set", "catalog must be set", "policy must be set", "guidance-reference must be set", "output must be set"},
+		},
+		{
+			name:    "duplicate output",
+			targets: []Target{complete("a", "out/plan.json"), complete("b", "out/../out/plan.json")},
+			wantErr: []string{`target "b": out/plan.json is also written by target "a"`},
+		},
+		{
+			name: "output collides with provenance",
+			targets: []Target{
+				func() Target {
+					target := complete("a", "plan.json")
+					target.Provenance = true
+					return target
+				}(),
+				complete("b", "plan.json.intoto.json"),
+			},
+			wantErr: []string{`target "b": plan.json.intoto.json is also written by target "a"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Project{Targets: tt.targets}).Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() succeeded, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
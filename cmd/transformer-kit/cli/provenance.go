@@ -0,0 +1,254 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	oscalTypes "github.com/defenseunicorns/go-oscal/src/types/oscal-1-1-3"
+)
+
+const (
+	// ProvenanceNamespace is the OSCAL property namespace for provenance metadata
+	// stamped on generated artifacts.
+	ProvenanceNamespace = "https://github.com/jpower432/opensource-securitycon-2025-oscal-in-action/provenance"
+
+	toolName = "transformer-kit"
+
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v1"
+	buildType           = "https://github.com/jpower432/opensource-securitycon-2025-oscal-in-action/transformer-kit/plan@v1"
+)
+
+// version is the transformer-kit version. It is set at build time with
+// -ldflags "-X github.com/jpower432/opensource-securitycon-2025-oscal-in-action/cmd/transformer-kit/cli.version=<version>".
+var version = ""
+
+type inputKind string
+
+const (
+	inputCatalog        inputKind = "catalog"
+	inputEvaluationPlan inputKind = "evaluation-plan"
+	inputPolicy         inputKind = "policy"
+)
+
+// artifactInput identifies a single Gemara artifact used to produce an OSCAL artifact.
+type artifactInput struct {
+	Kind    inputKind
+	Path    string
+	ID      string
+	Version string
+	SHA256  string
+}
+
+func newArtifactInput(kind inputKind, path, id, version string, data []byte) artifactInput {
+	return artifactInput{
+		Kind:    kind,
+		Path:    path,
+		ID:      id,
+		Version: version,
+		SHA256:  sha256Hex(data),
+	}
+}
+
+// stampMetadata adds properties to the OSCAL metadata recording the ID, version, and digest
+// of each input along with the tool version and source commit.
+func stampMetadata(metadata *oscalTypes.Metadata, inputs []artifactInput, sourcePath string) {
+	var props []oscalTypes.Property
+	if metadata.Props != nil {
+		props = *metadata.Props
+	}
+
+	for _, input := range inputs {
+		props = append(props,
+			provenanceProp(fmt.Sprintf("%s-id", input.Kind), input.ID, input.ID),
+			provenanceProp(fmt.Sprintf("%s-version", input.Kind), input.Version, input.ID),
+			provenanceProp(fmt.Sprintf("%s-sha256", input.Kind), input.SHA256, input.ID),
+		)
+	}
+
+	props = append(props, provenanceProp("tool-name", toolName, ""))
+	props = append(props, provenanceProp("tool-version", toolVersion(), ""))
+	if revision := toolRevision(); revision != "" {
+		props = append(props, provenanceProp("tool-revision", revision, ""))
+	}
+	if commit := gitCommit(filepath.Dir(sourcePath)); commit != "" {
+		props = append(props, provenanceProp("source-commit", commit, ""))
+	}
+
+	metadata.Props = &props
+}
+
+func provenanceProp(name, value, group string) oscalTypes.Property {
+	if value == "" {
+		value = "unknown"
+	}
+	return oscalTypes.Property{
+		Name:  name,
+		Value: value,
+		Ns:    ProvenanceNamespace,
+		Group: group,
+	}
+}
+
+// ProvenanceStatement is an in-toto v1 statement carrying a SLSA v1 provenance predicate.
+type ProvenanceStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     SLSAProvenance       `json:"predicate"`
+}
+
+// ResourceDescriptor identifies an artifact by name, location, and digest.
+type ResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest"`
+	// Annotations carry the Gemara artifact ID and version.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// SLSAProvenance is the SLSA v1 provenance predicate.
+type SLSAProvenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   map[string]any       `json:"externalParameters"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+type RunDetails struct {
+	Builder  Builder       `json:"builder"`
+	Metadata BuildMetadata `json:"metadata"`
+}
+
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+type BuildMetadata struct {
+	StartedOn  time.Time `json:"startedOn"`
+	FinishedOn time.Time `json:"finishedOn"`
+}
+
+// newProvenanceStatement creates a provenance statement for a generated artifact with
+// the given name and content.
+func newProvenanceStatement(name string, data []byte, opts planOptions, inputs []artifactInput, start time.Time) ProvenanceStatement {
+	dependencies := make([]ResourceDescriptor, 0, len(inputs))
+	for _, input := range inputs {
+		dependencies = append(dependencies, ResourceDescriptor{
+			Name:   string(input.Kind),
+			URI:    fmt.Sprintf("file://%s", filepath.ToSlash(input.Path)),
+			Digest: map[string]string{"sha256": input.SHA256},
+			Annotations: map[string]string{
+				"id":      input.ID,
+				"version": input.Version,
+			},
+		})
+	}
+
+	builderVersion := map[string]string{toolName: toolVersion()}
+	if commit := gitCommit(filepath.Dir(opts.PolicyPath)); commit != "" {
+		builderVersion["source-commit"] = commit
+	}
+
+	return ProvenanceStatement{
+		Type: inTotoStatementType,
+		Subject: []ResourceDescriptor{
+			{
+				Name:   name,
+				Digest: map[string]string{"sha256": sha256Hex(data)},
+			},
+		},
+		PredicateType: slsaProvenanceType,
+		Predicate: SLSAProvenance{
+			BuildDefinition: BuildDefinition{
+				BuildType: buildType,
+				ExternalParameters: map[string]any{
+					"target-component":   opts.TargetComponent,
+					"component-type":     opts.ComponentType,
+					"component-version":  opts.ComponentVersion,
+					"guidance-reference": opts.GuidanceRef,
+				},
+				ResolvedDependencies: dependencies,
+			},
+			RunDetails: RunDetails{
+				Builder: Builder{
+					ID:      toolName,
+					Version: builderVersion,
+				},
+				Metadata: BuildMetadata{
+					StartedOn:  start.UTC(),
+					FinishedOn: time.Now().UTC(),
+				},
+			},
+		},
+	}
+}
+
+func writeProvenance(path string, statement ProvenanceStatement) error {
+	data, err := json.MarshalIndent(statement, "", " ")
+	if err != nil {
+		return err
+	}
+	cleanedPath := filepath.Clean(path)
+	if err := os.WriteFile(cleanedPath, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write provenance %s: %w", cleanedPath, err)
+	}
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// toolVersion returns the version set at build time, falling back to the
+// module version recorded in the build info.
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "devel"
+}
+
+// toolRevision returns the VCS revision the tool was built from, if recorded.
+func toolRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+// gitCommit returns the HEAD commit of the git repository containing dir, or an
+// empty string if it cannot be determined.
+func gitCommit(dir string) string {
+	var out bytes.Buffer
+	cmd := exec.Command("git", "-C", dir, "rev-parse", "HEAD")
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out.String())
+}
@@ -10,5 +10,11 @@ func New() *cobra.Command {
 		Short: "transform CLI",
 	}
 	command.AddCommand(NewPlanCommand())
+	command.AddCommand(NewAllCommand())
+	command.AddCommand(NewImportCompDefCommand())
+	command.AddCommand(NewCrosswalkCommand())
+	command.AddCommand(NewKeygenCommand())
+	command.AddCommand(NewSignCommand())
+	command.AddCommand(NewVerifyCommand())
 	return command
 }
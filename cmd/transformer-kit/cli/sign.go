@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jpower432/opensource-securitycon-2025-oscal-in-action/internal/signing"
+)
+
+func NewKeygenCommand() *cobra.Command {
+	var privateKeyPath, publicKeyPath string
+
+	command := &cobra.Command{
+		Use:   "keygen",
+		Short: "Generate a key pair for signing OSCAL artifacts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			privatePEM, publicPEM, err := signing.GenerateKeyPair()
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(privateKeyPath, privatePEM, 0600); err != nil {
+				return err
+			}
+			return os.WriteFile(publicKeyPath, publicPEM, 0600)
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVar(&privateKeyPath, "private-key", "signing.key", "Path to write the private key")
+	flags.StringVar(&publicKeyPath, "public-key", "signing.pub", "Path to write the public key")
+	return command
+}
+
+func NewSignCommand() *cobra.Command {
+	var keyPath, signaturePath string
+
+	command := &cobra.Command{
+		Use:   "sign <oscal-json>",
+		Short: "Create a detached signature for an OSCAL JSON artifact",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := signing.LoadPrivateKey(keyPath)
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			sig, err := signing.Sign(data, key)
+			if err != nil {
+				return err
+			}
+			if signaturePath == "" {
+				signaturePath = signing.SignatureFile(args[0])
+			}
+			if err := signing.WriteSignature(signaturePath, sig); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Signed %s (key %s) -> %s\n", args[0], sig.KeyID, signaturePath)
+			return nil
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVarP(&keyPath, "key", "k", "", "Path to the PEM encoded private key")
+	flags.StringVarP(&signaturePath, "signature", "s", "", "Path to write the signature (defaults to <oscal-json>.sig)")
+	_ = command.MarkFlagRequired("key")
+	return command
+}
+
+func NewVerifyCommand() *cobra.Command {
+	var keyPath, signaturePath string
+
+	command := &cobra.Command{
+		Use:   "verify <oscal-json>",
+		Short: "Verify the detached signature of an OSCAL JSON artifact",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := verifyArtifact(args[0], signaturePath, keyPath); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Verified %s\n", args[0])
+			return nil
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVarP(&keyPath, "key", "k", "", "Path to the PEM encoded public key")
+	flags.StringVarP(&signaturePath, "signature", "s", "", "Path to the signature (defaults to <oscal-json>.sig)")
+	_ = command.MarkFlagRequired("key")
+	return command
+}
+
+// verifyArtifact checks the detached signature of an OSCAL JSON artifact. If
+// signaturePath is empty, the signature is expected next to the artifact.
+func verifyArtifact(path, signaturePath, publicKeyPath string) error {
+	key, err := signing.LoadPublicKey(publicKeyPath)
+	if err != nil {
+		return err
+	}
+	if signaturePath == "" {
+		signaturePath = signing.SignatureFile(path)
+	}
+	return signing.VerifyFile(path, signaturePath, key)
+}
@@ -0,0 +1,225 @@
+// Package signing produces and checks detached signatures over canonicalized
+// OSCAL JSON documents.
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Algorithm is the only supported signature algorithm.
+const Algorithm = "ed25519"
+
+// ErrInvalidSignature is returned when a signature does not match the document.
+var ErrInvalidSignature = errors.New("signature does not match document")
+
+// Signature is a detached signature over a canonicalized JSON document.
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	// KeyID is the hex-encoded SHA-256 digest of the PKIX public key.
+	KeyID string `json:"key-id"`
+	// Digest is the hex-encoded SHA-256 digest of the canonical payload.
+	Digest string `json:"digest"`
+	// Value is the base64-encoded signature over the canonical payload.
+	Value string `json:"signature"`
+}
+
+// SignatureFile returns the default location of the detached signature for path.
+func SignatureFile(path string) string {
+	return path + ".sig"
+}
+
+// Canonicalize returns a canonical encoding of a JSON document with
+// sorted object keys, no insignificant whitespace, and numbers preserved as written.
+// Formatting changes to the document do not change its canonical form.
+func Canonicalize(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON document: %w", err)
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after JSON document")
+	}
+
+	buf := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Sign canonicalizes the JSON document and signs it with the private key.
+func Sign(data []byte, key ed25519.PrivateKey) (Signature, error) {
+	canonical, err := Canonicalize(data)
+	if err != nil {
+		return Signature{}, err
+	}
+	keyID, err := KeyID(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		return Signature{}, err
+	}
+	digest := sha256.Sum256(canonical)
+	return Signature{
+		Algorithm: Algorithm,
+		KeyID:     keyID,
+		Digest:    hex.EncodeToString(digest[:]),
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(key, canonical)),
+	}, nil
+}
+
+// Verify canonicalizes the JSON document and checks the signature against the public key.
+func Verify(data []byte, sig Signature, key ed25519.PublicKey) error {
+	if sig.Algorithm != Algorithm {
+		return fmt.Errorf("unsupported signature algorithm %q", sig.Algorithm)
+	}
+	keyID, err := KeyID(key)
+	if err != nil {
+		return err
+	}
+	if sig.KeyID != keyID {
+		return fmt.Errorf("signature key ID %s does not match public key %s", sig.KeyID, keyID)
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	canonical, err := Canonicalize(data)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(canonical)
+	if sig.Digest != hex.EncodeToString(digest[:]) || !ed25519.Verify(key, canonical, value) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyFile checks the detached signature at sigPath for the JSON document at path.
+func VerifyFile(path, sigPath string, key ed25519.PublicKey) error {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	sig, err := ReadSignature(sigPath)
+	if err != nil {
+		return err
+	}
+	if err := Verify(data, sig, key); err != nil {
+		return fmt.Errorf("verifying %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadSignature reads a detached signature file.
+func ReadSignature(path string) (Signature, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return Signature{}, err
+	}
+	var sig Signature
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return Signature{}, fmt.Errorf("failed to decode signature %s: %w", path, err)
+	}
+	return sig, nil
+}
+
+// WriteSignature writes a detached signature file.
+func WriteSignature(path string, sig Signature) error {
+	data, err := json.MarshalIndent(sig, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Clean(path), append(data, '\n'), 0600)
+}
+
+// KeyID returns the hex-encoded SHA-256 digest of the PKIX encoded public key.
+func KeyID(key ed25519.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// GenerateKeyPair creates a new key pair encoded as PEM PKCS #8 private
+// and PKIX public keys.
+func GenerateKeyPair() (privatePEM []byte, publicPEM []byte, err error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, nil, err
+	}
+	privatePEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	publicPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	return privatePEM, publicPEM, nil
+}
+
+// LoadPrivateKey reads a PEM encoded PKCS #8 ed25519 private key.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an %s key", path, Algorithm)
+	}
+	return private, nil
+}
+
+// LoadPublicKey reads a PEM encoded PKIX ed25519 public key.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an %s key", path, Algorithm)
+	}
+	return public, nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded %s", path, blockType)
+	}
+	return block.Bytes, nil
+}
@@ -0,0 +1,305 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDocument = `{
+  "assessment-plan": {
+    "uuid": "0b7f9a8e-5f0c-4a6e-9d59-2f6f4f1b8c3a",
+    "metadata": {"title": "Plan <A&B>", "version": "1.0.0", "oscal-version": "1.1.3"},
+    "reviewed-controls": {"control-selections": [{"include-controls": [{"control-id": "ac-1"}]}]},
+    "props": [{"name": "threshold", "value": "2"}],
+    "count": 1.50
+  }
+}`
+
+// testKeyPair generates a key pair and writes it as PEM files, the way the keygen
+// command does, so the tests never depend on a committed key.
+func testKeyPair(t *testing.T) (ed25519.PrivateKey, ed25519.PublicKey) {
+	t.Helper()
+	privatePEM, publicPEM, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	dir := t.TempDir()
+	privatePath := filepath.Join(dir, "signing.key")
+	publicPath := filepath.Join(dir, "signing.pub")
+	if err := os.WriteFile(privatePath, privatePEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicPath, publicPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	private, err := LoadPrivateKey(privatePath)
+	if err != nil {
+		t.Fatalf("LoadPrivateKey() error = %v", err)
+	}
+	public, err := LoadPublicKey(publicPath)
+	if err != nil {
+		t.Fatalf("LoadPublicKey() error = %v", err)
+	}
+	return private, public
+}
+
+func TestSignVerify(t *testing.T) {
+	private, public := testKeyPair(t)
+	sig, err := Sign([]byte(testDocument), private)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if sig.Algorithm != Algorithm {
+		t.Errorf("algorithm = %s, want %s", sig.Algorithm, Algorithm)
+	}
+	if keyID, _ := KeyID(public); sig.KeyID != keyID {
+		t.Errorf("key ID = %s, want %s", sig.KeyID, keyID)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		wantErr  error
+	}{
+		{
+			name:     "signed document",
+			document: testDocument,
+		},
+		{
+			name:     "reformatted document",
+			document: strings.NewReplacer("\n", "", "  ", "", ": ", ":", ", ", ",").Replace(testDocument),
+		},
+		{
+			name: "reordered keys",
+			document: `{"assessment-plan": {"count": 1.50, "props": [{"value": "2", "name": "threshold"}],
+				"reviewed-controls": {"control-selections": [{"include-controls": [{"control-id": "ac-1"}]}]},
+				"metadata": {"oscal-version": "1.1.3", "version": "1.0.0", "title": "Plan <A&B>"},
+				"uuid": "0b7f9a8e-5f0c-4a6e-9d59-2f6f4f1b8c3a"}}`,
+		},
+		{
+			name:     "one byte changed",
+			document: strings.Replace(testDocument, `"control-id": "ac-1"`, `"control-id": "ac-2"`, 1),
+			wantErr:  ErrInvalidSignature,
+		},
+		{
+			name:     "number rewritten",
+			document: strings.Replace(testDocument, "1.50", "1.5", 1),
+			wantErr:  ErrInvalidSignature,
+		},
+		{
+			name:     "array element added",
+			document: strings.Replace(testDocument, `[{"name": "threshold", "value": "2"}]`, `[{"name": "threshold", "value": "2"}, {"name": "x", "value": "y"}]`, 1),
+			wantErr:  ErrInvalidSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify([]byte(tt.document), sig, public)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	private, public := testKeyPair(t)
+	_, otherPublic := testKeyPair(t)
+	sig, err := Sign([]byte(testDocument), private)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		sig    func(Signature) Signature
+		key    ed25519.PublicKey
+		errMsg string
+	}{
+		{
+			name:   "wrong key",
+			sig:    func(s Signature) Signature { return s },
+			key:    otherPublic,
+			errMsg: "does not match public key",
+		},
+		{
+			name: "wrong key with matching key ID",
+			sig: func(s Signature) Signature {
+				s.KeyID, _ = KeyID(otherPublic)
+				return s
+			},
+			key:    otherPublic,
+			errMsg: ErrInvalidSignature.Error(),
+		},
+		{
+			name: "unsupported algorithm",
+			sig: func(s Signature) Signature {
+				s.Algorithm = "rsa"
+				return s
+			},
+			key:    public,
+			errMsg: "unsupported signature algorithm",
+		},
+		{
+			name: "digest mismatch",
+			sig: func(s Signature) Signature {
+				s.Digest = strings.Repeat("0", len(s.Digest))
+				return s
+			},
+			key:    public,
+			errMsg: ErrInvalidSignature.Error(),
+		},
+		{
+			name: "signature not base64",
+			sig: func(s Signature) Signature {
+				s.Value = "not base64!"
+				return s
+			},
+			key:    public,
+			errMsg: "failed to decode signature",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify([]byte(testDocument), tt.sig(sig), tt.key)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Verify() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "sorted keys", input: `{"b": 1, "a": {"d": 2, "c": 3}}`, want: `{"a":{"c":3,"d":2},"b":1}`},
+		{name: "whitespace removed", input: "{\n  \"a\" : [ 1 , 2 ]\n}\n", want: `{"a":[1,2]}`},
+		{name: "array order kept", input: `[3, 1, 2]`, want: `[3,1,2]`},
+		{name: "numbers kept as written", input: `{"a": 1.50, "b": 1e3, "c": 12345678901234567890}`, want: `{"a":1.50,"b":1e3,"c":12345678901234567890}`},
+		{name: "HTML characters not escaped", input: `{"a": "<b>&"}`, want: `{"a":"<b>&"}`},
+		{name: "non-ASCII kept", input: `{"a": "prüfung"}`, want: `{"a":"prüfung"}`},
+		{name: "invalid JSON", input: `{"a":`, wantErr: true},
+		{name: "trailing data", input: `{"a": 1} {"b": 2}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Canonicalize([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Canonicalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Canonicalize() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeKeyOrderIndependent(t *testing.T) {
+	a, err := Canonicalize([]byte(`{"z": {"y": [1, {"b": true, "a": null}]}, "x": "w"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Canonicalize([]byte(`{"x": "w", "z": {"y": [1, {"a": null, "b": true}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("Canonicalize() = %s and %s, want the same canonical form", a, b)
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	private, public := testKeyPair(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "assessment-plan.json")
+	if err := os.WriteFile(path, []byte(testDocument), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := Sign([]byte(testDocument), private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSignature(SignatureFile(path), sig); err != nil {
+		t.Fatalf("WriteSignature() error = %v", err)
+	}
+	if err := VerifyFile(path, SignatureFile(path), public); err != nil {
+		t.Errorf("VerifyFile() error = %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Replace(testDocument, "ac-1", "ac-9", 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFile(path, SignatureFile(path), public); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyFile() of tampered document error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	privatePEM, publicPEM, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPrivateDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPublicDER, err := x509.MarshalPKIXPublicKey(ecKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		public  bool
+		errMsg  string
+	}{
+		{name: "private key", content: privatePEM},
+		{name: "public key", content: publicPEM, public: true},
+		{name: "public key as private key", content: publicPEM, errMsg: "does not contain a PEM encoded PRIVATE KEY"},
+		{name: "private key as public key", content: privatePEM, public: true, errMsg: "does not contain a PEM encoded PUBLIC KEY"},
+		{name: "not PEM", content: []byte("not a key"), errMsg: "does not contain a PEM encoded PRIVATE KEY"},
+		{name: "corrupt private key", content: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("corrupt")}), errMsg: "failed to parse private key"},
+		{name: "ECDSA private key", content: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPrivateDER}), errMsg: "is not an ed25519 key"},
+		{name: "ECDSA public key", content: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecPublicDER}), public: true, errMsg: "is not an ed25519 key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "key.pem")
+			if err := os.WriteFile(path, tt.content, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if tt.public {
+				_, err = LoadPublicKey(path)
+			} else {
+				_, err = LoadPrivateKey(path)
+			}
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("load error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("load error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}